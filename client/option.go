@@ -3,17 +3,24 @@ package client
 import (
 	"encoding/json"
 
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/shenjing023/vivy-polaris/contrib/accesslog"
+	"github.com/shenjing023/vivy-polaris/contrib/metrics/prometheus"
 	"github.com/shenjing023/vivy-polaris/contrib/registry"
+	"github.com/shenjing023/vivy-polaris/contrib/tracing"
+	"github.com/shenjing023/vivy-polaris/errors"
 	"github.com/shenjing023/vivy-polaris/options"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/propagation"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
 
 	"github.com/shenjing023/vivy-polaris/contrib/validator"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/stats"
 )
 
 /*	methodConfig配置信息
@@ -30,8 +37,16 @@ import (
 */
 
 type clientOptions struct {
-	opts          []grpc.DialOption
-	serviceConfig ServiceConfig
+	opts               []grpc.DialOption
+	interceptors       []grpc.UnaryClientInterceptor
+	streamInterceptors []grpc.StreamClientInterceptor
+	serviceConfig      ServiceConfig
+	clientError        bool
+	metrics            *prometheus.ClientMetrics
+	tracerProvider     trace.TracerProvider
+	meterProvider      metric.MeterProvider
+	otelOpts           []otelgrpc.Option
+	otelFilter         otelgrpc.Filter
 }
 
 type MethodName struct {
@@ -59,11 +74,31 @@ type ServiceConfig struct {
 
 func NewClientOptions(opts ...options.Option[clientOptions]) (*[]grpc.DialOption, error) {
 	copt := &clientOptions{
-		opts: make([]grpc.DialOption, 0),
+		opts:               make([]grpc.DialOption, 0),
+		interceptors:       make([]grpc.UnaryClientInterceptor, 0),
+		streamInterceptors: make([]grpc.StreamClientInterceptor, 0),
+		otelFilter:         tracing.DefaultFilter(),
 	}
 	for _, opt := range opts {
 		opt.Apply(copt)
 	}
+	if copt.clientError {
+		copt.interceptors = append([]grpc.UnaryClientInterceptor{errors.ClientErrorInterceptor}, copt.interceptors...)
+		copt.streamInterceptors = append([]grpc.StreamClientInterceptor{errors.ClientErrorStreamInterceptor}, copt.streamInterceptors...)
+	}
+	if copt.metrics != nil {
+		copt.interceptors = append([]grpc.UnaryClientInterceptor{copt.metrics.UnaryClientInterceptor()}, copt.interceptors...)
+		copt.streamInterceptors = append([]grpc.StreamClientInterceptor{copt.metrics.StreamClientInterceptor()}, copt.streamInterceptors...)
+	}
+	if copt.tracerProvider != nil || copt.meterProvider != nil {
+		copt.opts = append(copt.opts, grpc.WithStatsHandler(copt.statsHandler()))
+	}
+	if len(copt.interceptors) > 0 {
+		copt.opts = append(copt.opts, grpc.WithUnaryInterceptor(grpc_middleware.ChainUnaryClient(copt.interceptors...)))
+	}
+	if len(copt.streamInterceptors) > 0 {
+		copt.opts = append(copt.opts, grpc.WithStreamInterceptor(grpc_middleware.ChainStreamClient(copt.streamInterceptors...)))
+	}
 	sc, err := json.Marshal(copt.serviceConfig)
 	if err != nil {
 		return nil, err
@@ -108,18 +143,87 @@ func WithEtcdDiscovery(conf clientv3.Config, serviceDesc grpc.ServiceDesc) optio
 	})
 }
 
-func WithClientTracing(tp *sdktrace.TracerProvider) options.Option[clientOptions] {
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+// WithClientTracing traces the rpcs, streams and message events included, with tp.
+// The W3C Trace Context and Baggage are injected unless otelgrpc.WithPropagators
+// is in opts, e.g. tracing.NewPropagator(tracing.B3).
+// The global TracerProvider and propagator are left alone.
+func WithClientTracing(tp trace.TracerProvider, opts ...otelgrpc.Option) options.Option[clientOptions] {
 	return options.NewFuncOption(func(o *clientOptions) {
-		o.opts = append(o.opts, grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()))
+		o.tracerProvider = tp
+		o.otelOpts = append(o.otelOpts, opts...)
+	})
+}
+
+// WithClientTracingFilter sets the filter of the traced and measured rpcs,
+// default is tracing.DefaultFilter excluding the health checks and the reflection.
+// A nil filter keeps every rpc.
+func WithClientTracingFilter(f otelgrpc.Filter) options.Option[clientOptions] {
+	return options.NewFuncOption(func(o *clientOptions) {
+		o.otelFilter = f
 	})
 }
 
 // WithClientValidator validate fields,
-// all==true return all fields error, otherwise return first error
+// all==true return all fields error, otherwise return first error.
+// For streaming rpcs every sent message is validated.
 func WithClientValidator(all bool) options.Option[clientOptions] {
 	return options.NewFuncOption(func(so *clientOptions) {
-		so.opts = append(so.opts, grpc.WithUnaryInterceptor(validator.UnaryClientInterceptor(all)))
+		so.interceptors = append(so.interceptors, validator.UnaryClientInterceptor(all))
+		so.streamInterceptors = append(so.streamInterceptors, validator.StreamClientInterceptor(all))
 	})
 }
+
+// WithClientError transfer the status errors to *errors.Error with the code,
+// the message and the details, so they can be checked with errors.Is and errors.As.
+// It wraps every other interceptor, so their errors are transferred too, except the
+// metrics of WithClientMetrics which wrap it and see the status errors.
+func WithClientError() options.Option[clientOptions] {
+	return options.NewFuncOption(func(o *clientOptions) {
+		o.clientError = true
+	})
+}
+
+// WithAccessLog logs one record per rpc with l, the sensitive message fields
+// and metadata are redacted.
+func WithAccessLog(l *accesslog.Logger) options.Option[clientOptions] {
+	return options.NewFuncOption(func(o *clientOptions) {
+		o.interceptors = append(o.interceptors, l.UnaryClientInterceptor())
+		o.streamInterceptors = append(o.streamInterceptors, l.StreamClientInterceptor())
+	})
+}
+
+// WithClientMetrics records the prometheus metrics of every rpc,
+// it is always the outermost interceptor.
+func WithClientMetrics(m *prometheus.ClientMetrics) options.Option[clientOptions] {
+	return options.NewFuncOption(func(o *clientOptions) {
+		o.metrics = m
+	})
+}
+
+// WithClientMeterProvider records the otelgrpc rpc metrics with mp,
+// e.g. a MeterProvider from metrics.NewOTLPMeterProvider.
+func WithClientMeterProvider(mp metric.MeterProvider) options.Option[clientOptions] {
+	return options.NewFuncOption(func(o *clientOptions) {
+		o.meterProvider = mp
+	})
+}
+
+// statsHandler returns the otelgrpc stats handler tracing and measuring the rpcs,
+// the providers which are not set are noop.
+func (o *clientOptions) statsHandler() stats.Handler {
+	var tp trace.TracerProvider = tracenoop.NewTracerProvider()
+	if o.tracerProvider != nil {
+		tp = o.tracerProvider
+	}
+	var mp metric.MeterProvider = metricnoop.NewMeterProvider()
+	if o.meterProvider != nil {
+		mp = o.meterProvider
+	}
+	opts := append([]otelgrpc.Option{
+		otelgrpc.WithTracerProvider(tp),
+		otelgrpc.WithMeterProvider(mp),
+		otelgrpc.WithPropagators(tracing.NewPropagator()),
+		otelgrpc.WithMessageEvents(otelgrpc.ReceivedEvents, otelgrpc.SentEvents),
+	}, o.otelOpts...)
+	return tracing.NewClientHandler(o.otelFilter, opts...)
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/shenjing023/vivy-polaris/example/pb"
+	"github.com/shenjing023/vivy-polaris/options"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type greeter struct {
+	pb.UnimplementedGreeterServer
+}
+
+func (greeter) SayHello(ctx context.Context, req *pb.HelloRequest) (*pb.HelloReply, error) {
+	return &pb.HelloReply{Message: "hello " + req.GetName()}, nil
+}
+
+// echoDesc is a bidi streaming service echoing the received messages.
+var echoDesc = grpc.ServiceDesc{
+	ServiceName: "test.Echo",
+	HandlerType: (*any)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Echo",
+		ServerStreams: true,
+		ClientStreams: true,
+		Handler: func(srv any, stream grpc.ServerStream) error {
+			m := new(emptypb.Empty)
+			if err := stream.RecvMsg(m); err != nil {
+				return err
+			}
+			return stream.SendMsg(m)
+		},
+	}},
+}
+
+// withRecorder chains the interceptors appending name to calls.
+func withRecorder(name string, calls *[]string) options.Option[clientOptions] {
+	return options.NewFuncOption(func(o *clientOptions) {
+		o.interceptors = append(o.interceptors, func(ctx context.Context, method string, req, reply interface{},
+			cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			*calls = append(*calls, name)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		})
+		o.streamInterceptors = append(o.streamInterceptors, func(ctx context.Context, desc *grpc.StreamDesc,
+			cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			*calls = append(*calls, name)
+			return streamer(ctx, desc, cc, method, opts...)
+		})
+	})
+}
+
+// dial serves a greeter and an echo service over an in-memory listener and
+// returns a client connection built with opts.
+func dial(t *testing.T, opts ...options.Option[clientOptions]) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterGreeterServer(srv, greeter{})
+	srv.RegisterService(&echoDesc, nil)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	opts = append(opts, WithInsecure(), options.NewFuncOption(func(o *clientOptions) {
+		o.opts = append(o.opts, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}))
+	}))
+	conn, err := NewClientConn("passthrough:///bufnet", opts...)
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestInterceptorOrder(t *testing.T) {
+	var calls []string
+	conn := dial(t, withRecorder("first", &calls), withRecorder("second", &calls))
+
+	_, err := pb.NewGreeterClient(conn).SayHello(context.Background(), &pb.HelloRequest{Name: "vivy"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"first", "second"}, calls)
+
+	calls = nil
+	stream, err := conn.NewStream(context.Background(), &echoDesc.Streams[0], "/test.Echo/Echo")
+	assert.Nil(t, err)
+	assert.Nil(t, stream.SendMsg(new(emptypb.Empty)))
+	assert.Nil(t, stream.CloseSend())
+	assert.Nil(t, stream.RecvMsg(new(emptypb.Empty)))
+	assert.Equal(t, []string{"first", "second"}, calls)
+}
@@ -1,18 +1,17 @@
 package main
 
 import (
-    "flag"
+	"context"
+	"flag"
 	"fmt"
-	"net"
 	"os"
-	"os/signal"
 	"path"
-	"syscall"
 
     "log"
 	conf "{{.PkgName}}/config"
 	vp_server "github.com/shenjing023/vivy-polaris/server"
 	handler "{{.PkgName}}/internal"
+	"{{.PkgName}}/repository"
 	pb "{{.PkgName}}/{{.GRPCPath}}"
 )
 
@@ -32,23 +31,23 @@ func main() {
 }
 
 func runServer() {
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", conf.ServerCfg.Port))
-	if err != nil {
-		log.Fatalf("failed to listen: %+v", err)
-	}
 	s := vp_server.NewServer()
 	pb.Register{{.ServerName}}Server(s, &handler.Server{})
-	go func() {
-		if err := s.Serve(lis); err != nil {
-			log.Fatalf("failed to serve: %+v", err)
-		}
-	}()
-	log.Printf("%s server start success, port: %d", conf.ServerCfg.ServerName, conf.ServerCfg.Port)
-
-	// Wait for interrupt signal to gracefully shutdown the server
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
-	<-quit
-	log.Printf("signal %d received and shutdown service", quit)
-	s.GracefulStop()
+	app := vp_server.NewApp(s, fmt.Sprintf(":%d", conf.ServerCfg.Port),
+		vp_server.WithBeforeStart(func(ctx context.Context) error {
+			repository.Init()
+			return nil
+		}),
+		vp_server.WithAfterStart(func(ctx context.Context) error {
+			log.Printf("%s server start success, port: %d", conf.ServerCfg.ServerName, conf.ServerCfg.Port)
+			return nil
+		}),
+		vp_server.WithAfterStop(func(ctx context.Context) error {
+			repository.Close()
+			return nil
+		}),
+	)
+	if err := app.Run(); err != nil {
+		log.Fatalf("failed to run server: %+v", err)
+	}
 }
\ No newline at end of file
@@ -0,0 +1,189 @@
+// Package accesslog logs one record per rpc with the method, the code and the
+// duration, and optionally the peer, the metadata and the redacted messages.
+package accesslog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shenjing023/vivy-polaris/log"
+	"github.com/shenjing023/vivy-polaris/options"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Logger logs the rpcs passing through its interceptors.
+type Logger struct {
+	opts accessLogOptions
+}
+
+// NewLogger returns a Logger, by default every rpc is logged at Info with the peer.
+func NewLogger(opts ...options.Option[accessLogOptions]) *Logger {
+	return &Logger{opts: *newAccessLogOptions(opts...)}
+}
+
+// shouldLog applies the latency threshold and the sampling to the successful rpcs.
+func (o *accessLogOptions) shouldLog(method string, code codes.Code, d time.Duration) bool {
+	if code != codes.OK {
+		return true
+	}
+	if d < o.threshold {
+		return false
+	}
+	ratio, ok := o.ratios[method]
+	if !ok {
+		ratio = o.defaultRatio
+	}
+	return ratio >= 1 || rand.Float64() < ratio
+}
+
+func (o *accessLogOptions) metadataValue(md metadata.MD) slog.Value {
+	attrs := make([]slog.Attr, 0, len(md))
+	for k, v := range md {
+		if o.redactFields[k] {
+			attrs = append(attrs, slog.String(k, redacted))
+			continue
+		}
+		attrs = append(attrs, slog.String(k, strings.Join(v, ",")))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// record is an rpc to log, req and resp are nil for the streaming rpcs
+type record struct {
+	kind      string
+	method    string
+	start     time.Time
+	req, resp interface{}
+	err       error
+	md        metadata.MD
+}
+
+func (o *accessLogOptions) log(ctx context.Context, r record) {
+	d := time.Since(r.start)
+	code := status.Code(r.err)
+	if !o.shouldLog(r.method, code, d) {
+		return
+	}
+	level := o.level
+	if code != codes.OK && level < slog.LevelWarn {
+		level = slog.LevelWarn
+	}
+	logger := log.FromContext(ctx)
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("method", r.method),
+		slog.String("code", code.String()),
+		slog.Duration("duration", d),
+	}
+	if r.err != nil {
+		attrs = append(attrs, slog.String("error", status.Convert(r.err).Message()))
+	}
+	if o.fields&Peer != 0 {
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			attrs = append(attrs, slog.String("peer", p.Addr.String()))
+		}
+	}
+	if o.fields&Metadata != 0 && r.md != nil {
+		attrs = append(attrs, slog.Attr{Key: "metadata", Value: o.metadataValue(r.md)})
+	}
+	if o.fields&Request != 0 && r.req != nil {
+		attrs = append(attrs, slog.Attr{Key: "request", Value: o.value(r.req)})
+	}
+	if o.fields&Response != 0 && r.resp != nil && r.err == nil {
+		attrs = append(attrs, slog.Attr{Key: "response", Value: o.value(r.resp)})
+	}
+	logger.LogAttrs(ctx, level, r.kind, attrs...)
+}
+
+// UnaryServerInterceptor logs the unary rpcs handled by the server.
+func (l *Logger) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	o := &l.opts
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		md, _ := metadata.FromIncomingContext(ctx)
+		o.log(ctx, record{kind: "grpc server", method: info.FullMethod, start: start, req: req, resp: resp, err: err, md: md})
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor logs the streaming rpcs handled by the server once they end.
+func (l *Logger) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	o := &l.opts
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, stream)
+		ctx := stream.Context()
+		md, _ := metadata.FromIncomingContext(ctx)
+		o.log(ctx, record{kind: "grpc server", method: info.FullMethod, start: start, err: err, md: md})
+		return err
+	}
+}
+
+// UnaryClientInterceptor logs the unary rpcs sent by the client.
+func (l *Logger) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	o := &l.opts
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		start := time.Now()
+		var p peer.Peer
+		err := invoker(ctx, method, req, reply, cc, append(callOpts, grpc.Peer(&p))...)
+		md, _ := metadata.FromOutgoingContext(ctx)
+		o.log(peer.NewContext(ctx, &p), record{kind: "grpc client", method: method, start: start, req: req, resp: reply, err: err, md: md})
+		return err
+	}
+}
+
+// StreamClientInterceptor logs the streaming rpcs sent by the client once
+// RecvMsg returns an error, io.EOF being a successful end, or receives the
+// response of a client streaming rpc.
+func (l *Logger) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	o := &l.opts
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		md, _ := metadata.FromOutgoingContext(ctx)
+		r := record{kind: "grpc client", method: method, start: start, md: md}
+		p := &peer.Peer{}
+		ctx = peer.NewContext(ctx, p)
+		stream, err := streamer(ctx, desc, cc, method, append(callOpts, grpc.Peer(p))...)
+		if err != nil {
+			r.err = err
+			o.log(ctx, r)
+			return nil, err
+		}
+		return &clientStream{ClientStream: stream, ctx: ctx, opts: o, record: r, serverStreams: desc.ServerStreams}, nil
+	}
+}
+
+type clientStream struct {
+	grpc.ClientStream
+	ctx           context.Context // carries the peer filled at the end of the rpc
+	opts          *accessLogOptions
+	record        record
+	serverStreams bool
+	once          sync.Once
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil && s.serverStreams {
+		return nil
+	}
+	s.once.Do(func() {
+		if err != nil && err != io.EOF {
+			s.record.err = err
+		}
+		s.opts.log(s.ctx, s.record)
+	})
+	return err
+}
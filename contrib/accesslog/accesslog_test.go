@@ -0,0 +1,119 @@
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/shenjing023/vivy-polaris/example/pb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+	ok := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &pb.HelloReply{Message: "Hello"}, nil
+	}
+	fail := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer secret", "token", "secret", "x-tenant-id", "t1"))
+	req := &pb.HelloRequest{Name: "password"}
+
+	tests := []struct {
+		name    string
+		logger  *Logger
+		handler grpc.UnaryHandler
+		check   func(t *testing.T, m map[string]any)
+	}{
+		{"redact", NewLogger(WithFields(Request, Response, Metadata), WithRedactFields("name")), ok, func(t *testing.T, m map[string]any) {
+			assert.Equal(t, "INFO", m["level"])
+			assert.Equal(t, "OK", m["code"])
+			assert.Equal(t, `{"name":"[REDACTED]"}`, m["request"])
+			assert.Equal(t, `{"message":"Hello"}`, m["response"])
+			md := m["metadata"].(map[string]any)
+			assert.Equal(t, "[REDACTED]", md["authorization"])
+			assert.Equal(t, "[REDACTED]", md["token"])
+			assert.Equal(t, "t1", md["x-tenant-id"])
+		}},
+		{"error", NewLogger(), fail, func(t *testing.T, m map[string]any) {
+			assert.Equal(t, "WARN", m["level"])
+			assert.Equal(t, "NotFound", m["code"])
+			assert.Equal(t, "not found", m["error"])
+			assert.NotContains(t, m, "request")
+		}},
+		{"threshold", NewLogger(WithLatencyThreshold(time.Hour)), ok, nil},
+		{"sampling", NewLogger(WithSampling(0, info.FullMethod)), ok, nil},
+		{"sampling error", NewLogger(WithSampling(0)), fail, func(t *testing.T, m map[string]any) {
+			assert.Equal(t, "NotFound", m["code"])
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			tt.logger.UnaryServerInterceptor()(ctx, req, info, tt.handler)
+			if tt.check == nil {
+				assert.Zero(t, buf.Len())
+				return
+			}
+			m := map[string]any{}
+			assert.Nil(t, json.Unmarshal(buf.Bytes(), &m))
+			assert.Equal(t, info.FullMethod, m["method"])
+			tt.check(t, m)
+		})
+	}
+	// the request itself is not modified
+	assert.Equal(t, "password", req.GetName())
+}
+
+// fakeClientStream receives one message then io.EOF
+type fakeClientStream struct {
+	grpc.ClientStream
+	received bool
+}
+
+func (s *fakeClientStream) RecvMsg(m interface{}) error {
+	if s.received {
+		return io.EOF
+	}
+	s.received = true
+	return nil
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &fakeClientStream{}, nil
+	}
+	interceptor := NewLogger().StreamClientInterceptor()
+
+	// a client streaming rpc ends with its response
+	cs, err := interceptor(context.Background(), &grpc.StreamDesc{ClientStreams: true}, nil, "/test.Echo/Upload", streamer)
+	assert.Nil(t, err)
+	assert.Nil(t, cs.RecvMsg(nil))
+	assert.Contains(t, buf.String(), `"code":"OK"`)
+
+	// a server streaming rpc ends with io.EOF
+	buf.Reset()
+	cs, err = interceptor(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, "/test.Echo/Download", streamer)
+	assert.Nil(t, err)
+	assert.Nil(t, cs.RecvMsg(nil))
+	assert.Zero(t, buf.Len())
+	assert.Equal(t, io.EOF, cs.RecvMsg(nil))
+	assert.Contains(t, buf.String(), `"code":"OK"`)
+}
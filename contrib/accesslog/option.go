@@ -0,0 +1,123 @@
+package accesslog
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/shenjing023/vivy-polaris/options"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Field is an optional field of the access log, the method, the code and
+// the duration are always logged.
+type Field uint8
+
+const (
+	// Request logs the redacted request of the unary rpcs
+	Request Field = 1 << iota
+	// Response logs the redacted response of the unary rpcs
+	Response
+	// Peer logs the address of the peer
+	Peer
+	// Metadata logs the incoming metadata on the server, the outgoing one on the client
+	Metadata
+)
+
+// DefaultRedactFields are the message fields and metadata keys always redacted.
+var DefaultRedactFields = []string{
+	"authorization", "cookie", "set-cookie",
+	"password", "passwd", "secret", "client_secret",
+	"token", "access_token", "refresh_token", "id_token",
+	"api_key", "apikey", "private_key", "credit_card", "card_number", "cvv",
+}
+
+type accessLogOptions struct {
+	fields        Field
+	level         slog.Level
+	threshold     time.Duration
+	defaultRatio  float64
+	ratios        map[string]float64
+	redactFields  map[string]bool
+	redactOptions []protoreflect.ExtensionType
+}
+
+func newAccessLogOptions(opts ...options.Option[accessLogOptions]) *accessLogOptions {
+	o := &accessLogOptions{
+		fields:       Peer,
+		level:        slog.LevelInfo,
+		defaultRatio: 1,
+		ratios:       map[string]float64{},
+		redactFields: map[string]bool{},
+	}
+	for _, n := range DefaultRedactFields {
+		o.redactFields[n] = true
+	}
+	for _, opt := range opts {
+		opt.Apply(o)
+	}
+	return o
+}
+
+// WithFields sets the optional fields logged, default is Peer.
+func WithFields(fields ...Field) options.Option[accessLogOptions] {
+	return options.NewFuncOption(func(o *accessLogOptions) {
+		o.fields = 0
+		for _, f := range fields {
+			o.fields |= f
+		}
+	})
+}
+
+// WithLevel sets the level of the successful rpcs, default is Info.
+// The failed rpcs are logged at Warn at least.
+func WithLevel(level slog.Level) options.Option[accessLogOptions] {
+	return options.NewFuncOption(func(o *accessLogOptions) {
+		o.level = level
+	})
+}
+
+// WithLatencyThreshold logs only the rpcs lasting at least d, the failed rpcs
+// are always logged.
+func WithLatencyThreshold(d time.Duration) options.Option[accessLogOptions] {
+	return options.NewFuncOption(func(o *accessLogOptions) {
+		o.threshold = d
+	})
+}
+
+// WithSampling logs the ratio of the successful rpcs of methods, e.g. 0.01 logs
+// one rpc out of a hundred. Without methods the ratio is the default of every
+// method, which is 1. The failed rpcs are always logged.
+func WithSampling(ratio float64, methods ...string) options.Option[accessLogOptions] {
+	return options.NewFuncOption(func(o *accessLogOptions) {
+		if len(methods) == 0 {
+			o.defaultRatio = ratio
+			return
+		}
+		for _, m := range methods {
+			o.ratios[m] = ratio
+		}
+	})
+}
+
+// WithRedactFields redacts the message fields and the metadata keys of names,
+// the proto field names are matched at any depth. The fields annotated with
+// [debug_redact = true] and the names of DefaultRedactFields are always redacted.
+func WithRedactFields(names ...string) options.Option[accessLogOptions] {
+	return options.NewFuncOption(func(o *accessLogOptions) {
+		for _, n := range names {
+			o.redactFields[strings.ToLower(n)] = true
+		}
+	})
+}
+
+// WithRedactOption redacts the message fields annotated with the custom bool
+// field option ext, e.g.
+//
+//	extend google.protobuf.FieldOptions { bool sensitive = 50000; }
+//	string password = 2 [(sensitive) = true];
+func WithRedactOption(ext protoreflect.ExtensionType) options.Option[accessLogOptions] {
+	return options.NewFuncOption(func(o *accessLogOptions) {
+		o.redactOptions = append(o.redactOptions, ext)
+	})
+}
@@ -0,0 +1,91 @@
+package accesslog
+
+import (
+	"log/slog"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// redacted replaces the values of the redacted string and bytes fields,
+// the other kinds are cleared.
+const redacted = "[REDACTED]"
+
+// protoValue is redacted and marshalled only when the record is logged.
+type protoValue struct {
+	msg  proto.Message
+	opts *accessLogOptions
+}
+
+func (v protoValue) LogValue() slog.Value {
+	msg := proto.Clone(v.msg)
+	v.opts.redact(msg.ProtoReflect())
+	b, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	if err != nil {
+		return slog.StringValue(err.Error())
+	}
+	return slog.StringValue(string(b))
+}
+
+// value returns the logged value of the request or the response m.
+func (o *accessLogOptions) value(m interface{}) slog.Value {
+	if msg, ok := m.(proto.Message); ok {
+		return slog.AnyValue(protoValue{msg: msg, opts: o})
+	}
+	return slog.AnyValue(m)
+}
+
+func (o *accessLogOptions) isRedacted(fd protoreflect.FieldDescriptor) bool {
+	if o.redactFields[strings.ToLower(string(fd.Name()))] {
+		return true
+	}
+	fo, ok := fd.Options().(*descriptorpb.FieldOptions)
+	if !ok || fo == nil {
+		return false
+	}
+	if fo.GetDebugRedact() {
+		return true
+	}
+	for _, ext := range o.redactOptions {
+		if proto.HasExtension(fo, ext) {
+			if b, ok := proto.GetExtension(fo, ext).(bool); ok && b {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// redact redacts the fields of m in place, recursing into the nested messages.
+func (o *accessLogOptions) redact(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case o.isRedacted(fd):
+			if fd.IsList() || fd.IsMap() {
+				m.Clear(fd)
+			} else if fd.Kind() == protoreflect.StringKind {
+				m.Set(fd, protoreflect.ValueOfString(redacted))
+			} else if fd.Kind() == protoreflect.BytesKind {
+				m.Set(fd, protoreflect.ValueOfBytes([]byte(redacted)))
+			} else {
+				m.Clear(fd)
+			}
+		case fd.IsList() && fd.Message() != nil:
+			l := v.List()
+			for i := 0; i < l.Len(); i++ {
+				o.redact(l.Get(i).Message())
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				o.redact(mv.Message())
+				return true
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			o.redact(v.Message())
+		}
+		return true
+	})
+}
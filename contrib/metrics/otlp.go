@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/shenjing023/vivy-polaris/contrib/tracing"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// NewOTLPMeterProvider returns an OpenTelemetry MeterProvider exporting the metrics
+// to the OTLP collector at url every interval, described by res, e.g. the
+// Resource of tracing.NewResourceWithOptions sharing the attributes of the traces.
+// The exporter is configured further with opts, e.g. otlpmetricgrpc.WithInsecure,
+// otlpmetricgrpc.WithTLSCredentials or otlpmetricgrpc.WithHeaders.
+func NewOTLPMeterProvider(url string, res *resource.Resource, interval time.Duration, opts ...otlpmetricgrpc.Option) (*sdkmetric.MeterProvider, error) {
+	exp, err := otlpmetricgrpc.New(context.Background(), append([]otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(url)}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	return newMeterProvider(sdkmetric.NewPeriodicReader(exp, sdkmetric.WithInterval(interval)), res), nil
+}
+
+// NewInMemoryMeterProvider returns a MeterProvider whose metrics are kept in memory,
+// tests assert on them with reader.Collect and Find, no collector is needed.
+func NewInMemoryMeterProvider(serviceName string) (*sdkmetric.MeterProvider, *sdkmetric.ManualReader) {
+	reader := sdkmetric.NewManualReader()
+	return newMeterProvider(reader, tracing.NewResource(serviceName)), reader
+}
+
+func newMeterProvider(reader sdkmetric.Reader, res *resource.Resource) *sdkmetric.MeterProvider {
+	return sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithResource(res),
+	)
+}
+
+// Find returns the metric named name in rm.
+func Find(rm metricdata.ResourceMetrics, name string) (metricdata.Metrics, bool) {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m, true
+			}
+		}
+	}
+	return metricdata.Metrics{}, false
+}
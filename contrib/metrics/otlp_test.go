@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	vp_client "github.com/shenjing023/vivy-polaris/client"
+	"github.com/shenjing023/vivy-polaris/contrib/tracing"
+	"github.com/shenjing023/vivy-polaris/example/pb"
+	vp_server "github.com/shenjing023/vivy-polaris/server"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+type greeter struct {
+	pb.UnimplementedGreeterServer
+}
+
+func (greeter) SayHello(ctx context.Context, in *pb.HelloRequest) (*pb.HelloReply, error) {
+	return &pb.HelloReply{Message: "Hello " + in.GetName()}, nil
+}
+
+func TestInMemoryMeterProvider(t *testing.T) {
+	smp, sreader := NewInMemoryMeterProvider("test-server")
+	cmp, creader := NewInMemoryMeterProvider("test-client")
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	srv := vp_server.NewServer(vp_server.WithServerMeterProvider(smp))
+	pb.RegisterGreeterServer(srv, greeter{})
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := vp_client.NewClientConn(lis.Addr().String(), vp_client.WithInsecure(), vp_client.WithClientMeterProvider(cmp))
+	assert.Nil(t, err)
+	defer conn.Close()
+	_, err = pb.NewGreeterClient(conn).SayHello(context.Background(), &pb.HelloRequest{Name: "world"})
+	assert.Nil(t, err)
+
+	for name, reader := range map[string]interface {
+		Collect(context.Context, *metricdata.ResourceMetrics) error
+	}{"rpc.server.duration": sreader, "rpc.client.duration": creader} {
+		var rm metricdata.ResourceMetrics
+		assert.Nil(t, reader.Collect(context.Background(), &rm))
+		m, ok := Find(rm, name)
+		assert.True(t, ok, name)
+		assert.Equal(t, uint64(1), m.Data.(metricdata.Histogram[float64]).DataPoints[0].Count)
+	}
+}
+
+func TestOTLPMeterProvider(t *testing.T) {
+	res, err := tracing.NewResourceWithOptions(tracing.WithServiceName("test"),
+		tracing.WithVersion("v1.2.3"), tracing.WithEnvironment("staging"))
+	assert.Nil(t, err)
+	mp, err := NewOTLPMeterProvider("127.0.0.1:4317", res, time.Minute,
+		otlpmetricgrpc.WithInsecure(), otlpmetricgrpc.WithHeaders(map[string]string{"authorization": "Bearer token"}))
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	mp.Shutdown(ctx)
+
+	// the resource attributes of the traces reach the exported metrics
+	reader := sdkmetric.NewManualReader()
+	mp = newMeterProvider(reader, res)
+	counter, err := mp.Meter("test").Int64Counter("requests")
+	assert.Nil(t, err)
+	counter.Add(context.Background(), 1)
+	var rm metricdata.ResourceMetrics
+	assert.Nil(t, reader.Collect(context.Background(), &rm))
+	for k, v := range map[attribute.Key]string{
+		semconv.ServiceNameKey:           "test",
+		semconv.ServiceVersionKey:        "v1.2.3",
+		semconv.DeploymentEnvironmentKey: "staging",
+	} {
+		got, ok := rm.Resource.Set().Value(k)
+		assert.True(t, ok, k)
+		assert.Equal(t, v, got.AsString())
+	}
+}
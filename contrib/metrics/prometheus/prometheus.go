@@ -0,0 +1,372 @@
+// Package prometheus records the rpc metrics of the servers and the clients
+// with the same labels across the services.
+package prometheus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/shenjing023/vivy-polaris/contrib/ratelimit"
+	"github.com/shenjing023/vivy-polaris/contrib/validator"
+	"github.com/shenjing023/vivy-polaris/errors"
+	"github.com/shenjing023/vivy-polaris/options"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	Unary        = "unary"
+	ClientStream = "client_stream"
+	ServerStream = "server_stream"
+	BidiStream   = "bidi_stream"
+)
+
+type metricsOptions struct {
+	namespace  string
+	registerer prom.Registerer
+	buckets    []float64
+}
+
+// WithNamespace sets the namespace of the metrics, default is no namespace.
+func WithNamespace(ns string) options.Option[metricsOptions] {
+	return options.NewFuncOption(func(o *metricsOptions) {
+		o.namespace = ns
+	})
+}
+
+// WithRegisterer sets the registerer of the metrics, default is prometheus.DefaultRegisterer.
+func WithRegisterer(r prom.Registerer) options.Option[metricsOptions] {
+	return options.NewFuncOption(func(o *metricsOptions) {
+		o.registerer = r
+	})
+}
+
+// WithBuckets sets the buckets of the latency histogram in seconds, default is prometheus.DefBuckets.
+func WithBuckets(buckets []float64) options.Option[metricsOptions] {
+	return options.NewFuncOption(func(o *metricsOptions) {
+		o.buckets = buckets
+	})
+}
+
+// rpcMetrics are the metrics of one side, server or client
+type rpcMetrics struct {
+	handled  *prom.CounterVec
+	latency  *prom.HistogramVec
+	inFlight *prom.GaugeVec
+	msgSize  *prom.HistogramVec
+}
+
+func newMetricsOptions(opts ...options.Option[metricsOptions]) metricsOptions {
+	o := metricsOptions{
+		registerer: prom.DefaultRegisterer,
+		buckets:    prom.DefBuckets,
+	}
+	for _, opt := range opts {
+		opt.Apply(&o)
+	}
+	return o
+}
+
+func newRPCMetrics(side string, o metricsOptions) *rpcMetrics {
+	labels := []string{"grpc_type", "grpc_service", "grpc_method"}
+	m := &rpcMetrics{
+		handled: register(o.registerer, prom.NewCounterVec(prom.CounterOpts{
+			Namespace: o.namespace,
+			Name:      "grpc_" + side + "_handled_total",
+			Help:      "Total number of rpcs completed, regardless of success or failure.",
+		}, append(labels, "grpc_code"))),
+		latency: register(o.registerer, prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: o.namespace,
+			Name:      "grpc_" + side + "_handling_seconds",
+			Help:      "Histogram of the rpc latency in seconds.",
+			Buckets:   o.buckets,
+		}, labels)),
+		inFlight: register(o.registerer, prom.NewGaugeVec(prom.GaugeOpts{
+			Namespace: o.namespace,
+			Name:      "grpc_" + side + "_in_flight",
+			Help:      "Number of rpcs in flight.",
+		}, labels)),
+		msgSize: register(o.registerer, prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: o.namespace,
+			Name:      "grpc_" + side + "_msg_size_bytes",
+			Help:      "Histogram of the message sizes in bytes.",
+			Buckets:   prom.ExponentialBuckets(64, 4, 8),
+		}, append(labels, "direction"))),
+	}
+	return m
+}
+
+// register registers c, the already registered collector is reused so the
+// metrics can be created more than once with the same registerer.
+func register[T prom.Collector](r prom.Registerer, c T) T {
+	if err := r.Register(c); err != nil {
+		if are, ok := err.(prom.AlreadyRegisteredError); ok {
+			return are.ExistingCollector.(T)
+		}
+		panic(err)
+	}
+	return c
+}
+
+// splitMethod splits "/package.service/method"
+func splitMethod(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.Index(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", "unknown"
+}
+
+func streamType(clientStream, serverStream bool) string {
+	switch {
+	case clientStream && serverStream:
+		return BidiStream
+	case clientStream:
+		return ClientStream
+	case serverStream:
+		return ServerStream
+	}
+	return Unary
+}
+
+// call records one rpc
+type call struct {
+	m      *rpcMetrics
+	labels []string
+	start  time.Time
+}
+
+func (m *rpcMetrics) start(typ, fullMethod string) *call {
+	service, method := splitMethod(fullMethod)
+	c := &call{m: m, labels: []string{typ, service, method}, start: time.Now()}
+	m.inFlight.WithLabelValues(c.labels...).Inc()
+	return c
+}
+
+func (c *call) msg(direction string, msg interface{}) {
+	if pm, ok := msg.(proto.Message); ok {
+		c.m.msgSize.WithLabelValues(append(c.labels, direction)...).Observe(float64(proto.Size(pm)))
+	}
+}
+
+func (c *call) done(err error) {
+	c.m.inFlight.WithLabelValues(c.labels...).Dec()
+	c.m.latency.WithLabelValues(c.labels...).Observe(time.Since(c.start).Seconds())
+	c.m.handled.WithLabelValues(append(c.labels, status.Code(err).String())...).Inc()
+}
+
+// ServerMetrics records the server side rpc metrics.
+type ServerMetrics struct {
+	*rpcMetrics
+	rejected *prom.CounterVec
+	panics   *prom.CounterVec
+	gatherer prom.Gatherer
+}
+
+// NewServerMetrics creates and registers the server metrics.
+func NewServerMetrics(opts ...options.Option[metricsOptions]) *ServerMetrics {
+	o := newMetricsOptions(opts...)
+	return &ServerMetrics{
+		gatherer:   gatherer(o.registerer),
+		rpcMetrics: newRPCMetrics("server", o),
+		rejected: register(o.registerer, prom.NewCounterVec(prom.CounterOpts{
+			Namespace: o.namespace,
+			Name:      "grpc_server_rejected_total",
+			Help:      "Total number of rpcs rejected by the rate limiter, the load shedder or the validator.",
+		}, []string{"grpc_type", "grpc_service", "grpc_method", "reason"})),
+		panics: register(o.registerer, prom.NewCounterVec(prom.CounterOpts{
+			Namespace: o.namespace,
+			Name:      "grpc_server_panics_total",
+			Help:      "Total number of panics recovered from the rpcs.",
+		}, []string{"grpc_service", "grpc_method"})),
+	}
+}
+
+// PanicRecovered counts a panic recovered from the rpc fullMethod.
+func (m *ServerMetrics) PanicRecovered(fullMethod string) {
+	m.panics.WithLabelValues(splitMethod(fullMethod)).Inc()
+}
+
+// donePanicking records c as an Internal error if the rpc is panicking,
+// the panic goes on to the recovery interceptor.
+func (m *ServerMetrics) donePanicking(c *call) {
+	if p := recover(); p != nil {
+		m.done(c, status.Error(codes.Internal, errors.InternalError))
+		panic(p)
+	}
+}
+
+func (m *ServerMetrics) done(c *call, err error) {
+	c.done(err)
+	switch reason := errors.ErrorInfo(err).GetReason(); reason {
+	case ratelimit.Reason, ratelimit.OverloadReason, validator.Reason:
+		m.rejected.WithLabelValues(append(c.labels, reason)...).Inc()
+	}
+}
+
+// UnaryServerInterceptor records the metrics of the unary rpcs, it should be the
+// outermost interceptor so the rejections of the other interceptors are recorded.
+func (m *ServerMetrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		c := m.start(Unary, info.FullMethod)
+		defer m.donePanicking(c)
+		c.msg("received", req)
+		resp, err := handler(ctx, req)
+		if err == nil {
+			c.msg("sent", resp)
+		}
+		m.done(c, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records the metrics of the streaming rpcs.
+func (m *ServerMetrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		c := m.start(streamType(info.IsClientStream, info.IsServerStream), info.FullMethod)
+		defer m.donePanicking(c)
+		err := handler(srv, &serverStream{ServerStream: stream, c: c})
+		m.done(c, err)
+		return err
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	c *call
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.c.msg("sent", m)
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.c.msg("received", m)
+	}
+	return err
+}
+
+// ClientMetrics records the client side rpc metrics.
+type ClientMetrics struct {
+	*rpcMetrics
+}
+
+// NewClientMetrics creates and registers the client metrics.
+func NewClientMetrics(opts ...options.Option[metricsOptions]) *ClientMetrics {
+	return &ClientMetrics{rpcMetrics: newRPCMetrics("client", newMetricsOptions(opts...))}
+}
+
+// UnaryClientInterceptor records the metrics of the unary rpcs.
+func (m *ClientMetrics) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		c := m.start(Unary, method)
+		c.msg("sent", req)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err == nil {
+			c.msg("received", reply)
+		}
+		c.done(err)
+		return err
+	}
+}
+
+// StreamClientInterceptor records the metrics of the streaming rpcs,
+// the rpc is done when RecvMsg returns an error, io.EOF included,
+// receives the response of a client streaming rpc or when ctx is done.
+func (m *ClientMetrics) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		c := m.start(streamType(desc.ClientStreams, desc.ServerStreams), method)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			c.done(err)
+			return nil, err
+		}
+		s := &clientStream{ClientStream: cs, c: c, serverStreams: desc.ServerStreams, finished: make(chan struct{})}
+		// the stream abandoned by the caller is done once ctx is
+		go func() {
+			select {
+			case <-ctx.Done():
+				s.finish(status.FromContextError(ctx.Err()).Err())
+			case <-s.finished:
+			}
+		}()
+		return s, nil
+	}
+}
+
+type clientStream struct {
+	grpc.ClientStream
+	c             *call
+	serverStreams bool
+	once          sync.Once
+	finished      chan struct{}
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.c.msg("sent", m)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		s.c.msg("received", m)
+		if !s.serverStreams {
+			s.finish(nil)
+		}
+		return nil
+	}
+	if err == io.EOF {
+		s.finish(nil)
+	} else {
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() {
+		close(s.finished)
+		s.c.done(err)
+	})
+}
+
+// Handler returns the http handler of the metrics registered in prometheus.DefaultGatherer.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Handler returns the http handler of the metrics registered with the registerer
+// of WithRegisterer, it must also be a prometheus.Gatherer, e.g. a *prometheus.Registry,
+// otherwise the metrics of prometheus.DefaultGatherer are served.
+func (m *ServerMetrics) Handler() http.Handler {
+	if m.gatherer == prom.DefaultGatherer {
+		return promhttp.Handler()
+	}
+	return promhttp.HandlerFor(m.gatherer, promhttp.HandlerOpts{})
+}
+
+// gatherer returns the gatherer of the metrics registered with r.
+func gatherer(r prom.Registerer) prom.Gatherer {
+	if g, ok := r.(prom.Gatherer); ok {
+		return g
+	}
+	return prom.DefaultGatherer
+}
@@ -0,0 +1,63 @@
+package prometheus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/shenjing023/vivy-polaris/contrib/ratelimit"
+	"github.com/shenjing023/vivy-polaris/example/pb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestServerMetrics(t *testing.T) {
+	reg := prom.NewRegistry()
+	m := NewServerMetrics(WithRegisterer(reg))
+	// creating the metrics twice reuses the registered collectors
+	assert.NotPanics(t, func() { NewServerMetrics(WithRegisterer(reg)) })
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+	limit := ratelimit.UnaryServerInterceptor(ratelimit.NewTokenBucketRL(1, 1, info.FullMethod))
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &pb.HelloReply{Message: "hello"}, nil
+	}
+	interceptor := m.UnaryServerInterceptor()
+	for i := 0; i < 2; i++ {
+		interceptor(context.Background(), &pb.HelloRequest{Name: "world"}, info,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return limit(ctx, req, info, handler)
+			})
+	}
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.handled.WithLabelValues(Unary, "helloworld.Greeter", "SayHello", "OK")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.handled.WithLabelValues(Unary, "helloworld.Greeter", "SayHello", "ResourceExhausted")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.rejected.WithLabelValues(Unary, "helloworld.Greeter", "SayHello", ratelimit.Reason)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.inFlight.WithLabelValues(Unary, "helloworld.Greeter", "SayHello")))
+}
+
+// blockingClientStream never receives a message
+type blockingClientStream struct {
+	grpc.ClientStream
+}
+
+func TestClientStreamAbandoned(t *testing.T) {
+	m := NewClientMetrics(WithRegisterer(prom.NewRegistry()))
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return blockingClientStream{}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := m.StreamClientInterceptor()(ctx, &grpc.StreamDesc{ServerStreams: true}, nil, "/helloworld.Greeter/Watch", streamer)
+	assert.Nil(t, err)
+	labels := []string{ServerStream, "helloworld.Greeter", "Watch"}
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.inFlight.WithLabelValues(labels...)))
+
+	// the caller cancels without reading the stream to the end
+	cancel()
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(m.inFlight.WithLabelValues(labels...)) == 0
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.handled.WithLabelValues(append(labels, "Canceled")...)))
+}
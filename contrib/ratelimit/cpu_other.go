@@ -0,0 +1,11 @@
+//go:build !unix
+
+package ratelimit
+
+import "time"
+
+// processCPUTime is not available, the cpu usage is always 0 and the BBR
+// only sheds on the cpu usage given by WithCPU.
+func processCPUTime() time.Duration {
+	return 0
+}
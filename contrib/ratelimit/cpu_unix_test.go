@@ -0,0 +1,23 @@
+//go:build unix
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampleCPU(t *testing.T) {
+	tick := make(chan time.Time)
+	go sampleCPU(tick)
+	defer close(tick)
+	// burn the cpu until a sample is published
+	assert.Eventually(t, func() bool {
+		for start := time.Now(); time.Since(start) < 20*time.Millisecond; {
+		}
+		tick <- time.Now()
+		return processCPUUsage() > 0
+	}, 5*time.Second, time.Millisecond)
+}
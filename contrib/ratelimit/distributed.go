@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/shenjing023/vivy-polaris/options"
+	"golang.org/x/time/rate"
+)
+
+// Store keeps the token buckets shared by every replica.
+type Store interface {
+	// Take takes a token from the bucket of key refilled at rate per second
+	// up to burst tokens, it reports whether a token was available.
+	Take(ctx context.Context, key string, rate, burst int) (bool, error)
+}
+
+type distributedOptions struct {
+	timeout  time.Duration
+	backoff  time.Duration
+	replicas int
+	fallback *rate.Limiter
+}
+
+// WithStoreTimeout sets the timeout of a Take, default is 50ms.
+func WithStoreTimeout(d time.Duration) options.Option[distributedOptions] {
+	return options.NewFuncOption(func(o *distributedOptions) {
+		o.timeout = d
+	})
+}
+
+// WithStoreBackoff sets how long the local limiter is used after the store
+// failed before trying the store again, default is 1s.
+func WithStoreBackoff(d time.Duration) options.Option[distributedOptions] {
+	return options.NewFuncOption(func(o *distributedOptions) {
+		o.backoff = d
+	})
+}
+
+// WithReplicas sets the number of replicas sharing the bucket, the default local
+// limiter has the rate and the tokens of the shared bucket divided by n so the
+// replicas together keep the limit while the store is unreachable. Default is 1.
+func WithReplicas(n int) options.Option[distributedOptions] {
+	return options.NewFuncOption(func(o *distributedOptions) {
+		if n > 0 {
+			o.replicas = n
+		}
+	})
+}
+
+// WithFallback sets the local limiter used while the store is unreachable,
+// default has the rate and the tokens of the shared bucket divided by the
+// replicas of WithReplicas.
+func WithFallback(rl *rate.Limiter) options.Option[distributedOptions] {
+	return options.NewFuncOption(func(o *distributedOptions) {
+		o.fallback = rl
+	})
+}
+
+// DistributedTokenBucket is a token bucket shared by every replica through a Store.
+type DistributedTokenBucket struct {
+	method       string
+	store        Store
+	rate, tokens int
+	opts         distributedOptions
+	// unhealthyUntil is the unix nano time until which the fallback is used
+	unhealthyUntil atomic.Int64
+}
+
+// NewDistributedTokenBucketRL returns a token bucket of method shared through store,
+// it falls back to a local token bucket when the store is unreachable.
+func NewDistributedTokenBucketRL(store Store, rat, tokens int, method string, opts ...options.Option[distributedOptions]) RateLimiter {
+	tb := &DistributedTokenBucket{
+		method: method,
+		store:  store,
+		rate:   rat,
+		tokens: tokens,
+		opts: distributedOptions{
+			timeout:  50 * time.Millisecond,
+			backoff:  time.Second,
+			replicas: 1,
+		},
+	}
+	for _, opt := range opts {
+		opt.Apply(&tb.opts)
+	}
+	if tb.opts.fallback == nil {
+		n := tb.opts.replicas
+		tb.opts.fallback = rate.NewLimiter(rate.Limit(float64(rat)/float64(n)), max(1, tokens/n))
+	}
+	return tb
+}
+
+func (tb *DistributedTokenBucket) Limit() bool {
+	return tb.LimitContext(context.Background())
+}
+
+// LimitContext takes a token from the store within the store timeout and the
+// deadline of ctx. A request done before the store answered is rejected,
+// without falling back to the local limiter.
+func (tb *DistributedTokenBucket) LimitContext(ctx context.Context) bool {
+	if time.Now().UnixNano() < tb.unhealthyUntil.Load() {
+		return tb.opts.fallback.Allow()
+	}
+	tctx, cancel := context.WithTimeout(ctx, tb.opts.timeout)
+	defer cancel()
+	ok, err := tb.store.Take(tctx, tb.method, tb.rate, tb.tokens)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+		slog.Warn("rate limit store unreachable, fallback to local limiter", "method", tb.method, "err", err)
+		tb.unhealthyUntil.Store(time.Now().Add(tb.opts.backoff).UnixNano())
+		return tb.opts.fallback.Allow()
+	}
+	return ok
+}
+
+func (tb *DistributedTokenBucket) Method() string {
+	return tb.method
+}
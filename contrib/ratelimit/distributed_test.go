@@ -0,0 +1,33 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// storeFunc adapts a function to a Store
+type storeFunc func(ctx context.Context, key string, rate, burst int) (bool, error)
+
+func (f storeFunc) Take(ctx context.Context, key string, rate, burst int) (bool, error) {
+	return f(ctx, key, rate, burst)
+}
+
+func TestDistributedLimitContext(t *testing.T) {
+	// the store waits until the request is done
+	store := storeFunc(func(ctx context.Context, key string, rate, burst int) (bool, error) {
+		<-ctx.Done()
+		return false, ctx.Err()
+	})
+	tb := NewDistributedTokenBucketRL(store, 1, 1, "/helloworld.Greeter/SayHello", WithStoreTimeout(time.Minute)).(*DistributedTokenBucket)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.False(t, tb.LimitContext(ctx))
+	assert.Less(t, time.Since(start), time.Second)
+	// the store is not marked unreachable by the done request
+	assert.Zero(t, tb.unhealthyUntil.Load())
+}
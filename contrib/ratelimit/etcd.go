@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// maxTxnRetries is the number of compare-and-swap attempts of a Take,
+// the request is rejected once they all conflicted
+const maxTxnRetries = 5
+
+// bucket is the state of a token bucket stored in etcd
+type bucket struct {
+	Tokens float64 `json:"tokens"`
+	TS     int64   `json:"ts"` // unix nano time of the last refill
+}
+
+// EtcdStore keeps the token buckets in etcd, updated with compare-and-swap
+// transactions. The buckets are refilled from the local clocks, which must be
+// kept in sync. The buckets are attached to leases so they expire once full.
+type EtcdStore struct {
+	client *clientv3.Client
+	prefix string
+
+	mu     sync.Mutex
+	leases map[int64]etcdLease // by refill time in seconds
+}
+
+// etcdLease lives twice the refill time of its buckets and is replaced after
+// the refill time, so a bucket lives at least its refill time after its last take.
+type etcdLease struct {
+	id      clientv3.LeaseID
+	renewAt time.Time
+}
+
+// NewEtcdStore returns a Store keeping the buckets under prefix, e.g. "/ratelimit/".
+func NewEtcdStore(client *clientv3.Client, prefix string) *EtcdStore {
+	return &EtcdStore{client: client, prefix: prefix, leases: map[int64]etcdLease{}}
+}
+
+// lease returns the lease of the buckets refilled in refill seconds.
+func (s *EtcdStore) lease(ctx context.Context, refill int64) (clientv3.LeaseID, error) {
+	s.mu.Lock()
+	l, ok := s.leases[refill]
+	s.mu.Unlock()
+	if ok && time.Now().Before(l.renewAt) {
+		return l.id, nil
+	}
+	resp, err := s.client.Grant(ctx, 2*refill)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	s.leases[refill] = etcdLease{id: resp.ID, renewAt: time.Now().Add(time.Duration(refill) * time.Second)}
+	s.mu.Unlock()
+	return resp.ID, nil
+}
+
+func (s *EtcdStore) Take(ctx context.Context, key string, rate, burst int) (bool, error) {
+	key = s.prefix + key
+	var putOpts []clientv3.OpOption
+	// the buckets which are never refilled never expire
+	if rate > 0 {
+		refill := int64(math.Ceil(float64(burst)/float64(rate))) + 1
+		id, err := s.lease(ctx, refill)
+		if err != nil {
+			return false, err
+		}
+		putOpts = append(putOpts, clientv3.WithLease(id))
+	}
+	resp, err := s.client.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	for i := 0; i < maxTxnRetries; i++ {
+		b := bucket{Tokens: float64(burst), TS: time.Now().UnixNano()}
+		var rev int64
+		if len(resp.Kvs) > 0 {
+			rev = resp.Kvs[0].ModRevision
+			if err := json.Unmarshal(resp.Kvs[0].Value, &b); err != nil {
+				return false, errors.Wrapf(err, "invalid bucket %s", key)
+			}
+		}
+		now := time.Now().UnixNano()
+		elapsed := math.Max(0, float64(now-b.TS)/float64(time.Second))
+		b.Tokens = math.Min(float64(burst), b.Tokens+elapsed*float64(rate))
+		b.TS = now
+		allowed := b.Tokens >= 1
+		if allowed {
+			b.Tokens--
+		}
+		v, err := json.Marshal(b)
+		if err != nil {
+			return false, err
+		}
+		txn, err := s.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", rev)).
+			Then(clientv3.OpPut(key, string(v), putOpts...)).
+			Else(clientv3.OpGet(key)).
+			Commit()
+		if err != nil {
+			return false, err
+		}
+		if txn.Succeeded {
+			return allowed, nil
+		}
+		// another replica updated the bucket, retry with its state
+		resp = (*clientv3.GetResponse)(txn.Responses[0].GetResponseRange())
+	}
+	// the bucket is contended, rejecting keeps the shared limit where an error
+	// would let every replica fall back to its local limiter
+	return false, nil
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// etcdEndpoints returns the endpoints of ETCD_ENDPOINTS, comma separated,
+// the test is skipped if it is not set.
+func etcdEndpoints(t *testing.T) []string {
+	endpoints := os.Getenv("ETCD_ENDPOINTS")
+	if endpoints == "" {
+		t.Skip("ETCD_ENDPOINTS not set")
+	}
+	return strings.Split(endpoints, ",")
+}
+
+func TestEtcdStore(t *testing.T) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   etcdEndpoints(t),
+		DialTimeout: time.Second,
+	})
+	assert.Nil(t, err)
+	defer client.Close()
+	prefix := "/ratelimit-test/" + time.Now().Format(time.RFC3339Nano) + "/"
+	t.Cleanup(func() { client.Delete(context.Background(), prefix, clientv3.WithPrefix()) })
+	store := NewEtcdStore(client, prefix)
+
+	method := "/helloworld.Greeter/SayHello"
+	a := NewDistributedTokenBucketRL(store, 1, 2, method, WithStoreTimeout(time.Second))
+	b := NewDistributedTokenBucketRL(store, 1, 2, method, WithStoreTimeout(time.Second))
+	assert.True(t, a.Limit())
+	assert.True(t, b.Limit())
+	assert.False(t, a.Limit())
+	assert.False(t, b.Limit())
+
+	// the bucket expires with its lease
+	resp, err := client.Get(context.Background(), prefix+method)
+	assert.Nil(t, err)
+	assert.NotZero(t, resp.Kvs[0].Lease)
+	ttl, err := client.TimeToLive(context.Background(), clientv3.LeaseID(resp.Kvs[0].Lease))
+	assert.Nil(t, err)
+	assert.LessOrEqual(t, ttl.GrantedTTL, int64(6))
+}
+
+func TestEtcdStoreDown(t *testing.T) {
+	// nothing listens on the port, the client dials lazily
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{"127.0.0.1:1"},
+		DialTimeout: 50 * time.Millisecond,
+	})
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// the local limiter is used while the store is down
+	c := NewDistributedTokenBucketRL(NewEtcdStore(client, "/ratelimit/"), 1, 1, "/helloworld.Greeter/SayHello", WithStoreTimeout(50*time.Millisecond))
+	assert.True(t, c.Limit())
+	assert.False(t, c.Limit())
+}
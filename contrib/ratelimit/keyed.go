@@ -0,0 +1,195 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/shenjing023/vivy-polaris/options"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// ContextLimiter is a RateLimiter depending on the request, e.g. on the caller.
+// The interceptors call LimitContext instead of Limit when it is implemented.
+type ContextLimiter interface {
+	RateLimiter
+	LimitContext(ctx context.Context) bool
+}
+
+// KeyFunc extracts the key of the caller from the request context,
+// "" if the caller is unknown.
+type KeyFunc func(ctx context.Context) string
+
+// PeerIP keys the callers by the ip of the peer.
+func PeerIP() KeyFunc {
+	return func(ctx context.Context) string {
+		p, ok := peer.FromContext(ctx)
+		if !ok || p.Addr == nil {
+			return ""
+		}
+		addr := p.Addr.String()
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			return host
+		}
+		return addr
+	}
+}
+
+// MetadataKey keys the callers by the first value of the incoming metadata header,
+// e.g. x-tenant-id. The header is set by the caller, which can spoof the key of
+// another caller or rotate it to get fresh buckets and evict the others, so it
+// must be set by a trusted gateway, otherwise prefer TLSPrincipal.
+func MetadataKey(header string) KeyFunc {
+	header = strings.ToLower(header)
+	return func(ctx context.Context) string {
+		if vs := metadata.ValueFromIncomingContext(ctx, header); len(vs) > 0 {
+			return vs[0]
+		}
+		return ""
+	}
+}
+
+// TLSPrincipal keys the callers by the common name of their client certificate.
+func TLSPrincipal() KeyFunc {
+	return func(ctx context.Context) string {
+		p, ok := peer.FromContext(ctx)
+		if !ok {
+			return ""
+		}
+		info, ok := p.AuthInfo.(credentials.TLSInfo)
+		if !ok || len(info.State.PeerCertificates) == 0 {
+			return ""
+		}
+		return info.State.PeerCertificates[0].Subject.CommonName
+	}
+}
+
+// Limit is the rate per second and the burst of a bucket
+type Limit struct {
+	Rate   int
+	Tokens int
+}
+
+type keyedOptions struct {
+	classify func(key string) string
+	classes  map[string]Limit
+	maxKeys  int
+	ttl      time.Duration
+	unknown  *Limit
+}
+
+// WithClassifier sets the class of the keys, the classes without a limit
+// of WithKeyClass get the default one.
+func WithClassifier(f func(key string) string) options.Option[keyedOptions] {
+	return options.NewFuncOption(func(o *keyedOptions) {
+		o.classify = f
+	})
+}
+
+// WithKeyClass sets the limit of every key of class, without WithClassifier
+// the class is the key itself, e.g. WithKeyClass("tenant-a", 100, 200).
+func WithKeyClass(class string, rat, tokens int) options.Option[keyedOptions] {
+	return options.NewFuncOption(func(o *keyedOptions) {
+		o.classes[class] = Limit{Rate: rat, Tokens: tokens}
+	})
+}
+
+// WithUnknownLimit allows the callers whose key is "" within the rate and the
+// tokens of a bucket they share, by default they are rejected.
+func WithUnknownLimit(rat, tokens int) options.Option[keyedOptions] {
+	return options.NewFuncOption(func(o *keyedOptions) {
+		o.unknown = &Limit{Rate: rat, Tokens: tokens}
+	})
+}
+
+// WithMaxKeys bounds the number of buckets, the least recently used are
+// evicted and start full again when their caller comes back. Default is 10000.
+func WithMaxKeys(n int) options.Option[keyedOptions] {
+	return options.NewFuncOption(func(o *keyedOptions) {
+		o.maxKeys = n
+	})
+}
+
+// WithKeyTTL evicts the buckets unused for d, default is 10m.
+func WithKeyTTL(d time.Duration) options.Option[keyedOptions] {
+	return options.NewFuncOption(func(o *keyedOptions) {
+		o.ttl = d
+	})
+}
+
+// KeyedTokenBucket is a token bucket per caller of a method.
+type KeyedTokenBucket struct {
+	method  string
+	key     KeyFunc
+	limit   Limit
+	opts    keyedOptions
+	buckets *lru[*rate.Limiter]
+	unknown *rate.Limiter // nil rejects the unknown callers
+}
+
+// NewKeyedTokenBucketRL returns a token bucket per key of method, the keys
+// without a class limit get rat and tokens. The unknown callers, whose key is "",
+// are rejected unless WithUnknownLimit is used.
+func NewKeyedTokenBucketRL(method string, key KeyFunc, rat, tokens int, opts ...options.Option[keyedOptions]) RateLimiter {
+	tb := &KeyedTokenBucket{
+		method: method,
+		key:    key,
+		limit:  Limit{Rate: rat, Tokens: tokens},
+		opts: keyedOptions{
+			classify: func(key string) string { return key },
+			classes:  map[string]Limit{},
+			maxKeys:  10000,
+			ttl:      10 * time.Minute,
+		},
+	}
+	for _, opt := range opts {
+		opt.Apply(&tb.opts)
+	}
+	tb.buckets = newLRU[*rate.Limiter](tb.opts.maxKeys, tb.opts.ttl)
+	if u := tb.opts.unknown; u != nil {
+		tb.unknown = rate.NewLimiter(rate.Limit(u.Rate), u.Tokens)
+	}
+	return tb
+}
+
+// Limit limits the unknown callers, the interceptors call LimitContext.
+func (tb *KeyedTokenBucket) Limit() bool {
+	return tb.LimitKey("")
+}
+
+func (tb *KeyedTokenBucket) LimitContext(ctx context.Context) bool {
+	return tb.LimitKey(tb.key(ctx))
+}
+
+// LimitKey reports whether the caller of key is allowed.
+func (tb *KeyedTokenBucket) LimitKey(key string) bool {
+	b := tb.bucket(key)
+	return b != nil && b.Allow()
+}
+
+func (tb *KeyedTokenBucket) peek(ctx context.Context) bool {
+	b := tb.bucket(tb.key(ctx))
+	return b != nil && available(b)
+}
+
+// bucket returns the bucket of key, nil if the unknown callers are rejected.
+func (tb *KeyedTokenBucket) bucket(key string) *rate.Limiter {
+	if key == "" {
+		return tb.unknown
+	}
+	return tb.buckets.getOrAdd(key, func() *rate.Limiter {
+		l, ok := tb.opts.classes[tb.opts.classify(key)]
+		if !ok {
+			l = tb.limit
+		}
+		return rate.NewLimiter(rate.Limit(l.Rate), l.Tokens)
+	})
+}
+
+func (tb *KeyedTokenBucket) Method() string {
+	return tb.method
+}
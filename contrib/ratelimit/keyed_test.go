@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestKeyedTokenBucket(t *testing.T) {
+	method := "/helloworld.Greeter/SayHello"
+	tb := NewKeyedTokenBucketRL(method, MetadataKey("X-Tenant-Id"), 1, 1,
+		WithClassifier(func(key string) string {
+			if strings.HasPrefix(key, "premium-") {
+				return "premium"
+			}
+			return key
+		}),
+		WithKeyClass("premium", 1, 3))
+	interceptor := UnaryServerInterceptor(tb)
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	call := func(tenant string) codes.Code {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", tenant))
+		_, err := interceptor(ctx, nil, info, handler)
+		return status.Code(err)
+	}
+
+	// a noisy tenant doesn't exhaust the budget of the others
+	assert.Equal(t, codes.OK, call("a"))
+	assert.Equal(t, codes.ResourceExhausted, call("a"))
+	assert.Equal(t, codes.OK, call("b"))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, codes.OK, call("premium-c"))
+	}
+	assert.Equal(t, codes.ResourceExhausted, call("premium-c"))
+
+	// the unknown callers are rejected
+	assert.Equal(t, codes.ResourceExhausted, call(""))
+	_, err := interceptor(context.Background(), nil, info, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// or share their own bucket
+	tb = NewKeyedTokenBucketRL(method, MetadataKey("x-tenant-id"), 1, 1, WithUnknownLimit(1, 2))
+	interceptor = UnaryServerInterceptor(tb)
+	assert.Equal(t, codes.OK, call(""))
+	assert.Equal(t, codes.OK, call(""))
+	assert.Equal(t, codes.ResourceExhausted, call(""))
+	assert.Equal(t, codes.OK, call("a"))
+}
+
+func TestLRU(t *testing.T) {
+	now := time.Now()
+	c := newLRU[int](2, time.Minute)
+	c.now = func() time.Time { return now }
+	n := 0
+	newValue := func() int { n++; return n }
+
+	assert.Equal(t, 1, c.getOrAdd("a", newValue))
+	assert.Equal(t, 2, c.getOrAdd("b", newValue))
+	assert.Equal(t, 1, c.getOrAdd("a", newValue))
+	// b is the least recently used
+	assert.Equal(t, 3, c.getOrAdd("c", newValue))
+	assert.Equal(t, 2, c.len())
+	assert.Equal(t, 4, c.getOrAdd("b", newValue))
+
+	// every entry expired
+	now = now.Add(time.Minute)
+	assert.Equal(t, 5, c.getOrAdd("d", newValue))
+	assert.Equal(t, 1, c.len())
+}
+
+func TestPeerIP(t *testing.T) {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234}})
+	assert.Equal(t, "10.0.0.1", PeerIP()(ctx))
+	assert.Equal(t, "", PeerIP()(context.Background()))
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lru is a map bounded in size, the least recently used entries and the
+// entries unused for ttl are evicted.
+type lru[V any] struct {
+	mu      sync.Mutex
+	maxSize int
+	ttl     time.Duration
+	ll      *list.List
+	items   map[string]*list.Element
+	now     func() time.Time
+}
+
+type lruEntry[V any] struct {
+	key      string
+	value    V
+	accessed time.Time
+}
+
+func newLRU[V any](maxSize int, ttl time.Duration) *lru[V] {
+	return &lru[V]{
+		maxSize: maxSize,
+		ttl:     ttl,
+		ll:      list.New(),
+		items:   map[string]*list.Element{},
+		now:     time.Now,
+	}
+}
+
+// getOrAdd returns the value of key, created by newValue if absent or expired.
+func (c *lru[V]) getOrAdd(key string, newValue func() V) V {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if e, ok := c.items[key]; ok {
+		ent := e.Value.(*lruEntry[V])
+		if c.ttl <= 0 || now.Sub(ent.accessed) < c.ttl {
+			ent.accessed = now
+			c.ll.MoveToFront(e)
+			return ent.value
+		}
+		c.remove(e)
+	}
+	c.evict(now)
+	ent := &lruEntry[V]{key: key, value: newValue(), accessed: now}
+	c.items[key] = c.ll.PushFront(ent)
+	return ent.value
+}
+
+// evict removes the expired entries and the least recently used ones
+// until there is room for a new entry.
+func (c *lru[V]) evict(now time.Time) {
+	for e := c.ll.Back(); e != nil; e = c.ll.Back() {
+		ent := e.Value.(*lruEntry[V])
+		expired := c.ttl > 0 && now.Sub(ent.accessed) >= c.ttl
+		if !expired && (c.maxSize <= 0 || c.ll.Len() < c.maxSize) {
+			return
+		}
+		c.remove(e)
+	}
+}
+
+func (c *lru[V]) remove(e *list.Element) {
+	c.ll.Remove(e)
+	delete(c.items, e.Value.(*lruEntry[V]).key)
+}
+
+func (c *lru[V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
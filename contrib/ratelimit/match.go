@@ -0,0 +1,69 @@
+package ratelimit
+
+import "strings"
+
+const (
+	// AnyMethod matches every method without a more specific limiter
+	AnyMethod = "*"
+	// serviceWildcard suffixes the patterns matching every method of a service,
+	// e.g. /helloworld.Greeter/*
+	serviceWildcard = "/*"
+)
+
+// patternTable resolves the values of a method by precedence: the values of
+// the exact method, else of its service pattern, else of the global pattern.
+type patternTable[V any] struct {
+	methods  map[string][]V
+	services map[string][]V // keyed by /package.Service
+	global   []V
+}
+
+func newPatternTable[V any]() *patternTable[V] {
+	return &patternTable[V]{
+		methods:  map[string][]V{},
+		services: map[string][]V{},
+	}
+}
+
+func newLimiterTable(limiters []RateLimiter) *patternTable[RateLimiter] {
+	t := newPatternTable[RateLimiter]()
+	for _, l := range limiters {
+		t.add(l.Method(), l)
+	}
+	return t
+}
+
+// add adds v to the values of pattern.
+func (t *patternTable[V]) add(pattern string, v V) {
+	switch {
+	case pattern == AnyMethod:
+		t.global = append(t.global, v)
+	case strings.HasSuffix(pattern, serviceWildcard):
+		svc := normalize(strings.TrimSuffix(pattern, serviceWildcard))
+		t.services[svc] = append(t.services[svc], v)
+	default:
+		m := normalize(pattern)
+		t.methods[m] = append(t.methods[m], v)
+	}
+}
+
+// normalize adds the leading slash of the grpc full method names.
+func normalize(pattern string) string {
+	if !strings.HasPrefix(pattern, "/") {
+		return "/" + pattern
+	}
+	return pattern
+}
+
+// lookup returns the values of the most specific pattern matching method.
+func (t *patternTable[V]) lookup(method string) []V {
+	if vs, ok := t.methods[method]; ok {
+		return vs
+	}
+	if i := strings.LastIndexByte(method, '/'); i > 0 {
+		if vs, ok := t.services[method[:i]]; ok {
+			return vs
+		}
+	}
+	return t.global
+}
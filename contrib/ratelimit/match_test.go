@@ -0,0 +1,29 @@
+package ratelimit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterTable(t *testing.T) {
+	sayHello := NewTokenBucketRL(1, 1, "/helloworld.Greeter/SayHello")
+	greeter := NewTokenBucketRL(1, 1, "helloworld.Greeter/*")
+	global := NewTokenBucketRL(1, 1, AnyMethod)
+	table := newLimiterTable([]RateLimiter{global, greeter, sayHello})
+
+	tests := []struct {
+		method string
+		want   []RateLimiter
+	}{
+		{"/helloworld.Greeter/SayHello", []RateLimiter{sayHello}},
+		{"/helloworld.Greeter/SayGoodbye", []RateLimiter{greeter}},
+		{"/grpc.health.v1.Health/Check", []RateLimiter{global}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			assert.Equal(t, tt.want, table.lookup(tt.method))
+		})
+	}
+	assert.Empty(t, newLimiterTable([]RateLimiter{sayHello}).lookup("/helloworld.Greeter/SayGoodbye"))
+}
@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// LBPair is the leak rate of the bucket of a method.
+type LBPair struct {
+	Method  string        // The method name, /package.Service/* or * for every method
+	Rate    int           // The request per second
+	MaxWait time.Duration // The longest time a request waits in the bucket
+}
+
+// LeakyBucket lets the requests through at a constant rate, they wait in the
+// bucket instead of being rejected unless they would wait more than maxWait.
+type LeakyBucket struct {
+	method  string
+	rl      *rate.Limiter
+	maxWait time.Duration
+}
+
+// NewLeakyBucketRL returns a leaky bucket limiter of method,
+// it rejects every request when rat is not positive.
+func NewLeakyBucketRL(rat int, maxWait time.Duration, method string) RateLimiter {
+	burst := 1
+	if rat <= 0 {
+		rat, burst = 0, 0
+	}
+	return &LeakyBucket{
+		method:  method,
+		rl:      rate.NewLimiter(rate.Limit(rat), burst),
+		maxWait: maxWait,
+	}
+}
+
+// Limit waits for the turn of the request, up to maxWait.
+func (lb *LeakyBucket) Limit() bool {
+	return lb.LimitContext(context.Background())
+}
+
+// LimitContext waits for the turn of the request, up to maxWait or until ctx is done.
+func (lb *LeakyBucket) LimitContext(ctx context.Context) bool {
+	r := lb.rl.Reserve()
+	if !r.OK() {
+		return false
+	}
+	delay := r.Delay()
+	if delay == 0 {
+		return true
+	}
+	if delay > lb.maxWait {
+		r.Cancel()
+		return false
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		r.Cancel()
+		return false
+	}
+}
+
+func (lb *LeakyBucket) Method() string {
+	return lb.method
+}
+
+// Acquirer is a RateLimiter holding a resource while the request is handled.
+// The interceptors call Acquire instead of Limit and release it once the
+// handler returns.
+type Acquirer interface {
+	RateLimiter
+	Acquire(ctx context.Context) (release func(), ok bool)
+}
+
+// CLPair is the maximum in-flight requests of a method.
+type CLPair struct {
+	Method      string // The method name, /package.Service/* or * for every method
+	MaxInFlight int    // The number of requests handled concurrently
+}
+
+// ConcurrencyLimiter rejects the requests beyond maxInFlight in-flight ones,
+// e.g. to protect a database with a connection cap.
+type ConcurrencyLimiter struct {
+	method string
+	sem    chan struct{}
+}
+
+// NewConcurrencyRL returns a concurrency limiter of method, the interceptors
+// hold one of its slots while the request is handled. It rejects every request
+// when maxInFlight is not positive.
+func NewConcurrencyRL(maxInFlight int, method string) RateLimiter {
+	maxInFlight = max(0, maxInFlight)
+	return &ConcurrencyLimiter{
+		method: method,
+		sem:    make(chan struct{}, maxInFlight),
+	}
+}
+
+// Limit reports whether a slot is free without taking it, the interceptors
+// take one with Acquire.
+func (cl *ConcurrencyLimiter) Limit() bool {
+	return len(cl.sem) < cap(cl.sem)
+}
+
+// Acquire takes a slot if one is free, release gives it back.
+func (cl *ConcurrencyLimiter) Acquire(context.Context) (release func(), ok bool) {
+	select {
+	case cl.sem <- struct{}{}:
+		return cl.release, true
+	default:
+		return nil, false
+	}
+}
+
+func (cl *ConcurrencyLimiter) release() {
+	<-cl.sem
+}
+
+// InFlight returns the number of in-flight requests.
+func (cl *ConcurrencyLimiter) InFlight() int {
+	return len(cl.sem)
+}
+
+func (cl *ConcurrencyLimiter) Method() string {
+	return cl.method
+}
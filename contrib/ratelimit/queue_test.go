@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLeakyBucket(t *testing.T) {
+	lb := NewLeakyBucketRL(20, 60*time.Millisecond, "m")
+	start := time.Now()
+	// the first passes, the next ones wait 50ms for their turn
+	assert.True(t, lb.Limit())
+	assert.True(t, lb.Limit())
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	assert.True(t, lb.Limit())
+	// the waiting request leaves the bucket once its context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, lb.(ContextLimiter).LimitContext(ctx))
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	method := "/helloworld.Greeter/SayHello"
+	cl := NewConcurrencyRL(1, method).(*ConcurrencyLimiter)
+	interceptor := UnaryServerInterceptor(cl)
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+
+	entered, done := make(chan struct{}), make(chan struct{})
+	go interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		close(entered)
+		<-done
+		return nil, nil
+	})
+	<-entered
+	assert.Equal(t, 1, cl.InFlight())
+	_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	close(done)
+	assert.Eventually(t, func() bool { return cl.InFlight() == 0 }, time.Second, time.Millisecond)
+
+	// the slot is released when a following limiter rejects
+	interceptor = UnaryServerInterceptor(cl, NewTokenBucketRL(1, 0, method))
+	_, err = interceptor(context.Background(), nil, info, nil)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, 0, cl.InFlight())
+
+	// Limit does not take a slot, Acquire takes one until it is released
+	assert.True(t, cl.Limit())
+	assert.True(t, cl.Limit())
+	release, ok := cl.Acquire(context.Background())
+	assert.True(t, ok)
+	assert.False(t, cl.Limit())
+	_, ok = cl.Acquire(context.Background())
+	assert.False(t, ok)
+	release()
+	assert.Equal(t, 0, cl.InFlight())
+}
+
+func TestAcquireOrder(t *testing.T) {
+	method := "/helloworld.Greeter/SayHello"
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+	ok := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+
+	// the token is not spent when the concurrency limiter rejects
+	cl := NewConcurrencyRL(1, method).(*ConcurrencyLimiter)
+	tb := NewTokenBucketRL(0, 1, method).(*TokenBucket)
+	release, acquired := cl.Acquire(context.Background())
+	assert.True(t, acquired)
+	_, err := UnaryServerInterceptor(cl, tb)(context.Background(), nil, info, ok)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	release()
+	_, err = UnaryServerInterceptor(tb)(context.Background(), nil, info, ok)
+	assert.Nil(t, err)
+
+	// the slot is not held while the leaky bucket waits
+	lb := NewLeakyBucketRL(10, time.Second, method)
+	assert.True(t, lb.Limit())
+	done := make(chan error, 1)
+	go func() {
+		_, err := UnaryServerInterceptor(cl, lb)(context.Background(), nil, info, ok)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 0, cl.InFlight())
+	assert.Nil(t, <-done)
+}
@@ -2,13 +2,18 @@ package ratelimit
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/shenjing023/vivy-polaris/errors"
+	"github.com/shenjing023/vivy-polaris/errors/errcode"
 	"golang.org/x/time/rate"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
+// Reason is the ErrorInfo reason of the rate limited requests
+const Reason = "RATE_LIMITED"
+
 type RateLimiter interface {
 	Limit() bool
 	Method() string
@@ -20,7 +25,7 @@ type TokenBucket struct {
 }
 
 type TBPair struct {
-	Method string // The method name
+	Method string // The method name, /package.Service/* or * for every method
 	Rate   int    // The request per second
 	Tokens int    // The number of tokens in bucket
 }
@@ -29,24 +34,118 @@ func (tb *TokenBucket) Limit() bool {
 	return tb.rl.Allow()
 }
 
+func (tb *TokenBucket) peek(context.Context) bool {
+	return available(tb.rl)
+}
+
+// available reports whether rl has a token, the limiters of rate 0 keep
+// their remaining tokens in the burst.
+func available(rl *rate.Limiter) bool {
+	if rl.Limit() == 0 {
+		return rl.Burst() >= 1
+	}
+	return rl.Tokens() >= 1
+}
+
 func (tb *TokenBucket) Method() string {
 	return tb.method
 }
 
 // UnaryServerInterceptor returns a new unary server interceptors that performs request rate limiting.
+// The Method of a limiter is a full method name, a service pattern such as
+// /helloworld.Greeter/* or AnyMethod, only the limiters of the most specific
+// pattern matching a request apply.
 func UnaryServerInterceptor(limiters ...RateLimiter) grpc.UnaryServerInterceptor {
+	table := newLimiterTable(limiters)
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		for _, limiter := range limiters {
-			if limiter.Method() == info.FullMethod {
-				if !limiter.Limit() {
-					return nil, status.Errorf(codes.ResourceExhausted, "method [%s] rate limit exceeded", info.FullMethod)
-				}
-			}
+		release, err := acquire(ctx, info.FullMethod, table.lookup(info.FullMethod))
+		if err != nil {
+			return nil, err
 		}
+		defer release()
 		return handler(ctx, req)
 	}
 }
 
+// StreamServerInterceptor returns a new stream server interceptor that performs rate limiting on the request.
+// The limiters are matched as in UnaryServerInterceptor.
+func StreamServerInterceptor(limiters ...RateLimiter) grpc.StreamServerInterceptor {
+	table := newLimiterTable(limiters)
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		release, err := acquire(stream.Context(), info.FullMethod, table.lookup(info.FullMethod))
+		if err != nil {
+			return err
+		}
+		defer release()
+		return handler(srv, stream)
+	}
+}
+
+// peeker is a RateLimiter reporting whether it would allow a request without
+// spending a token.
+type peeker interface {
+	peek(ctx context.Context) bool
+}
+
+// acquire checks the limiters matching method, release frees the resources held by
+// the Acquirers once the request is handled.
+//
+// The peekers are checked first, then the other limiters run in order, which
+// may wait, then the Acquirers, so no Acquirer is held while waiting. The peekers
+// spend their tokens last, once every other limiter allowed the request.
+func acquire(ctx context.Context, method string, limiters []RateLimiter) (release func(), err error) {
+	var releases []func()
+	release = func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+	for _, limiter := range limiters {
+		if p, ok := limiter.(peeker); ok && !p.peek(ctx) {
+			return nil, limitErr(method)
+		}
+	}
+	for _, limiter := range limiters {
+		switch limiter.(type) {
+		case peeker, Acquirer:
+			continue
+		}
+		if !allow(ctx, limiter) {
+			return nil, limitErr(method)
+		}
+	}
+	for _, limiter := range limiters {
+		if a, ok := limiter.(Acquirer); ok {
+			r, ok := a.Acquire(ctx)
+			if !ok {
+				release()
+				return nil, limitErr(method)
+			}
+			releases = append(releases, r)
+		}
+	}
+	for _, limiter := range limiters {
+		if _, ok := limiter.(peeker); ok && !allow(ctx, limiter) {
+			// another request took the token since the peek
+			release()
+			return nil, limitErr(method)
+		}
+	}
+	return release, nil
+}
+
+func allow(ctx context.Context, limiter RateLimiter) bool {
+	if cl, ok := limiter.(ContextLimiter); ok {
+		return cl.LimitContext(ctx)
+	}
+	return limiter.Limit()
+}
+
+func limitErr(method string) error {
+	return errors.NewServiceErr(codes.ResourceExhausted, fmt.Errorf("method [%s] rate limit exceeded", method)).
+		WithErrorInfo(Reason, errcode.Domain, map[string]string{"method": method}).GRPCStatus().Err()
+}
+
 func NewTokenBucketRL(rat, tokens int, method string) RateLimiter {
 	return &TokenBucket{
 		method: method,
@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	store := NewRedisStore(client, "ratelimit:")
+
+	// two replicas share the bucket
+	method := "/helloworld.Greeter/SayHello"
+	a := NewDistributedTokenBucketRL(store, 1, 2, method)
+	b := NewDistributedTokenBucketRL(store, 1, 2, method)
+	assert.True(t, a.Limit())
+	assert.True(t, b.Limit())
+	assert.False(t, a.Limit())
+	assert.False(t, b.Limit())
+	assert.True(t, mr.Exists("ratelimit:"+method))
+	assert.Equal(t, 3*time.Second, mr.TTL("ratelimit:"+method))
+
+	// a bucket never refilled never expires
+	quota := "/helloworld.Greeter/Quota"
+	q := NewDistributedTokenBucketRL(store, 0, 1, quota)
+	assert.True(t, q.Limit())
+	assert.False(t, q.Limit())
+	assert.True(t, mr.Exists("ratelimit:"+quota))
+	assert.Zero(t, mr.TTL("ratelimit:"+quota))
+
+	// the local limiter is used while the store is down
+	mr.Close()
+	c := NewDistributedTokenBucketRL(store, 1, 1, method, WithStoreTimeout(10*time.Millisecond))
+	assert.True(t, c.Limit())
+	assert.False(t, c.Limit())
+
+	// the replicas share the limit while the store is down
+	d := NewDistributedTokenBucketRL(store, 1, 4, method, WithStoreTimeout(10*time.Millisecond), WithReplicas(2))
+	assert.True(t, d.Limit())
+	assert.True(t, d.Limit())
+	assert.False(t, d.Limit())
+}
@@ -0,0 +1,330 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shenjing023/vivy-polaris/errors"
+	"github.com/shenjing023/vivy-polaris/errors/errcode"
+	"github.com/shenjing023/vivy-polaris/options"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// OverloadReason is the ErrorInfo reason of the requests shed by the BBR
+const OverloadReason = "OVERLOADED"
+
+// Priority of a method, the requests of the lower priorities are shed first.
+type Priority int
+
+const (
+	PriorityLow      Priority = iota - 1 // shed at half the capacity
+	PriorityNormal                       // shed at the capacity, the default
+	PriorityHigh                         // shed at 1.5 times the capacity
+	PriorityCritical                     // shed at twice the capacity
+)
+
+// factor scales the in-flight limit of the priority.
+func (p Priority) factor() float64 {
+	switch p {
+	case PriorityLow:
+		return 0.5
+	case PriorityHigh:
+		return 1.5
+	case PriorityCritical:
+		return 2
+	default:
+		return 1
+	}
+}
+
+const (
+	defaultBBRWindow  = 10 * time.Second
+	defaultBBRBuckets = 100
+)
+
+type bbrOptions struct {
+	window        time.Duration
+	buckets       int
+	cpuThreshold  float64
+	latencyFactor float64
+	coolDown      time.Duration
+	cpu           func() float64
+	priorities    *patternTable[Priority]
+}
+
+// WithWindow sets the window of the learned statistics and its number of
+// buckets, default is 10s in 100 buckets. The default is kept when window or
+// buckets is not positive or the buckets would be shorter than 1ms.
+func WithWindow(window time.Duration, buckets int) options.Option[bbrOptions] {
+	return options.NewFuncOption(func(o *bbrOptions) {
+		o.window = window
+		o.buckets = buckets
+	})
+}
+
+// WithCPUThreshold sets the cpu usage of the process, between 0 and 1, above
+// which the requests are shed, default is 0.8.
+// The cpu time of the process is only read on unix, elsewhere the default cpu
+// usage is always 0 and the threshold is never passed unless WithCPU is set.
+func WithCPUThreshold(threshold float64) options.Option[bbrOptions] {
+	return options.NewFuncOption(func(o *bbrOptions) {
+		o.cpuThreshold = threshold
+	})
+}
+
+// WithLatencyFactor sheds the requests once the latency grows beyond factor
+// times the learned minimum latency, i.e. when the requests queue up.
+// Default is 0, which disables it.
+func WithLatencyFactor(factor float64) options.Option[bbrOptions] {
+	return options.NewFuncOption(func(o *bbrOptions) {
+		o.latencyFactor = factor
+	})
+}
+
+// WithCoolDown keeps shedding for d after the last shed request even if the
+// cpu usage went back below the threshold, default is 1s.
+func WithCoolDown(d time.Duration) options.Option[bbrOptions] {
+	return options.NewFuncOption(func(o *bbrOptions) {
+		o.coolDown = d
+	})
+}
+
+// WithCPU sets the cpu usage source, default is the cpu time of the process
+// over the wall time of GOMAXPROCS cpus.
+func WithCPU(f func() float64) options.Option[bbrOptions] {
+	return options.NewFuncOption(func(o *bbrOptions) {
+		o.cpu = f
+	})
+}
+
+// WithPriority sets the priority of the methods, which can be patterns as
+// for the limiters, default is PriorityNormal.
+func WithPriority(p Priority, methods ...string) options.Option[bbrOptions] {
+	return options.NewFuncOption(func(o *bbrOptions) {
+		for _, m := range methods {
+			o.priorities.add(m, p)
+		}
+	})
+}
+
+// BBR sheds the requests when the server is overloaded, i.e. when the cpu usage
+// or the latency passes its threshold, and the in-flight requests exceed the
+// learned capacity: the maximum pass rate times the minimum latency.
+type BBR struct {
+	opts     bbrOptions
+	inFlight atomic.Int64
+	lastDrop atomic.Int64 // unix nano time of the last shed request
+	stats    *rollingStats
+	now      func() time.Time
+}
+
+// NewBBR returns a load shedder of every method.
+func NewBBR(opts ...options.Option[bbrOptions]) *BBR {
+	o := bbrOptions{
+		window:       defaultBBRWindow,
+		buckets:      defaultBBRBuckets,
+		cpuThreshold: 0.8,
+		coolDown:     time.Second,
+		priorities:   newPatternTable[Priority](),
+	}
+	for _, opt := range opts {
+		opt.Apply(&o)
+	}
+	if o.window <= 0 || o.buckets <= 0 || o.window/time.Duration(o.buckets) < time.Millisecond {
+		o.window, o.buckets = defaultBBRWindow, defaultBBRBuckets
+	}
+	if o.cpu == nil {
+		o.cpu = processCPUUsage
+	}
+	return &BBR{opts: o, stats: newRollingStats(o.window, o.buckets), now: time.Now}
+}
+
+// maxInFlight returns the learned capacity scaled by the priority, 0 until
+// enough requests were handled.
+func (b *BBR) maxInFlight(now time.Time, p Priority) int64 {
+	maxPass, minRT := b.stats.maxPassMinRT(now)
+	if maxPass == 0 || minRT == 0 {
+		return 0
+	}
+	capacity := float64(maxPass) * float64(minRT) / float64(b.stats.bucket)
+	return int64(math.Max(1, math.Ceil(capacity*p.factor())))
+}
+
+func (b *BBR) overloaded(now time.Time) bool {
+	if b.opts.cpu() >= b.opts.cpuThreshold {
+		return true
+	}
+	if b.opts.latencyFactor > 0 {
+		_, minRT := b.stats.maxPassMinRT(now)
+		if rt := b.stats.lastRT(now); minRT > 0 && float64(rt) > float64(minRT)*b.opts.latencyFactor {
+			return true
+		}
+	}
+	return now.Sub(time.Unix(0, b.lastDrop.Load())) < b.opts.coolDown
+}
+
+// admit reports whether a request of method passes the shedding, without
+// taking an in-flight slot.
+func (b *BBR) admit(now time.Time, method string) bool {
+	p := PriorityNormal
+	if ps := b.opts.priorities.lookup(method); len(ps) > 0 {
+		p = ps[len(ps)-1]
+	}
+	if b.overloaded(now) {
+		if max := b.maxInFlight(now, p); max > 0 && b.inFlight.Load() >= max {
+			b.lastDrop.Store(now.UnixNano())
+			return false
+		}
+	}
+	return true
+}
+
+// Allow admits a request of method, done must be called once it is handled.
+func (b *BBR) Allow(method string) (done func(), ok bool) {
+	now := b.now()
+	if !b.admit(now, method) {
+		return nil, false
+	}
+	b.inFlight.Add(1)
+	return func() {
+		end := b.now()
+		b.inFlight.Add(-1)
+		b.stats.add(end, end.Sub(now))
+	}, true
+}
+
+// InFlight returns the number of in-flight requests.
+func (b *BBR) InFlight() int64 {
+	return b.inFlight.Load()
+}
+
+func (b *BBR) overloadErr(method string) error {
+	return errors.NewServiceErr(codes.ResourceExhausted, fmt.Errorf("method [%s] shed, server overloaded", method)).
+		WithErrorInfo(OverloadReason, errcode.Domain, map[string]string{"method": method}).
+		WithRetryInfo(b.opts.coolDown).GRPCStatus().Err()
+}
+
+// UnaryServerInterceptor sheds the unary requests while the server is overloaded.
+func (b *BBR) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		done, ok := b.Allow(info.FullMethod)
+		if !ok {
+			return nil, b.overloadErr(info.FullMethod)
+		}
+		defer done()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor sheds the new streams while the server is overloaded.
+// The admitted streams neither hold an in-flight slot nor feed the latency
+// statistics, their lifetime says nothing about the load of the server.
+func (b *BBR) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !b.admit(b.now(), info.FullMethod) {
+			return b.overloadErr(info.FullMethod)
+		}
+		return handler(srv, stream)
+	}
+}
+
+// rollingStats counts the handled requests and their latency in buckets.
+type rollingStats struct {
+	mu      sync.Mutex
+	bucket  time.Duration
+	buckets []statBucket
+}
+
+type statBucket struct {
+	start time.Time
+	pass  int64
+	rt    time.Duration // sum of the latencies
+}
+
+func newRollingStats(window time.Duration, n int) *rollingStats {
+	return &rollingStats{bucket: window / time.Duration(n), buckets: make([]statBucket, n)}
+}
+
+func (s *rollingStats) slot(now time.Time) (*statBucket, time.Time) {
+	start := now.Truncate(s.bucket)
+	return &s.buckets[int(start.UnixNano()/int64(s.bucket))%len(s.buckets)], start
+}
+
+func (s *rollingStats) add(now time.Time, rt time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, start := s.slot(now)
+	if !b.start.Equal(start) {
+		*b = statBucket{start: start}
+	}
+	b.pass++
+	b.rt += rt
+}
+
+// maxPassMinRT returns the maximum pass count and the minimum average latency
+// of the complete buckets of the window.
+func (s *rollingStats) maxPassMinRT(now time.Time) (maxPass int64, minRT time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := now.Truncate(s.bucket)
+	oldest := current.Add(-s.bucket * time.Duration(len(s.buckets)))
+	for _, b := range s.buckets {
+		if b.pass == 0 || !b.start.Before(current) || b.start.Before(oldest) {
+			continue
+		}
+		maxPass = max(maxPass, b.pass)
+		if rt := b.rt / time.Duration(b.pass); minRT == 0 || rt < minRT {
+			minRT = rt
+		}
+	}
+	return maxPass, minRT
+}
+
+// lastRT returns the average latency of the last complete bucket.
+func (s *rollingStats) lastRT(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := now.Truncate(s.bucket).Add(-s.bucket)
+	b, _ := s.slot(prev)
+	if !b.start.Equal(prev) || b.pass == 0 {
+		return 0
+	}
+	return b.rt / time.Duration(b.pass)
+}
+
+const cpuSampleInterval = 250 * time.Millisecond
+
+var (
+	cpuOnce sync.Once
+	// cpuMillionths is the last sampled cpu usage of the process, in millionths
+	cpuMillionths atomic.Int64
+)
+
+// processCPUUsage returns the last cpu usage sampled in the background, the
+// sampling goroutine is started by the first call and runs for the lifetime
+// of the process, shared by every BBR.
+func processCPUUsage() float64 {
+	cpuOnce.Do(func() {
+		go sampleCPU(time.NewTicker(cpuSampleInterval).C)
+	})
+	return float64(cpuMillionths.Load()) / 1e6
+}
+
+// sampleCPU publishes the cpu usage of the process over every tick.
+func sampleCPU(tick <-chan time.Time) {
+	last, lastCPU := time.Now(), processCPUTime()
+	for now := range tick {
+		cpu := processCPUTime()
+		if elapsed := now.Sub(last); elapsed > 0 {
+			usage := float64(cpu-lastCPU) / float64(elapsed) / float64(runtime.GOMAXPROCS(0))
+			cpuMillionths.Store(int64(usage * 1e6))
+		}
+		last, lastCPU = now, cpu
+	}
+}
@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shenjing023/vivy-polaris/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBBR(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cpu := 0.1
+	b := NewBBR(
+		WithWindow(time.Second, 10),
+		WithCPU(func() float64 { return cpu }),
+		WithPriority(PriorityCritical, "/helloworld.Greeter/*"),
+		WithPriority(PriorityLow, "/helloworld.Greeter/Batch"),
+	)
+	b.now = func() time.Time { return now }
+
+	// learn 10 requests of 100ms per 100ms bucket, i.e. a capacity of 10 in-flight requests
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 10; j++ {
+			done, ok := b.Allow("/svc/M")
+			assert.True(t, ok)
+			now = now.Add(100 * time.Millisecond)
+			done()
+			now = now.Add(-100 * time.Millisecond)
+		}
+		now = now.Add(100 * time.Millisecond)
+	}
+	now = now.Add(100 * time.Millisecond)
+	assert.Equal(t, int64(10), b.maxInFlight(now, PriorityNormal))
+
+	fill := func(method string) (dones []func()) {
+		for i := 0; i < 100; i++ {
+			done, ok := b.Allow(method)
+			if !ok {
+				break
+			}
+			dones = append(dones, done)
+		}
+		return dones
+	}
+	// not shed below the cpu threshold
+	dones := fill("/svc/M")
+	assert.Len(t, dones, 100)
+	for _, done := range dones {
+		done()
+	}
+
+	// the low priority methods are shed first, the critical ones last
+	cpu = 0.9
+	assert.Len(t, fill("/helloworld.Greeter/Batch"), 5)
+	assert.Len(t, fill("/svc/M"), 5)
+	critical := fill("/helloworld.Greeter/SayHello")
+	assert.Len(t, critical, 10)
+
+	// the rejection is a ResourceExhausted with the overload reason
+	_, err := b.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/svc/M"}, nil)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, OverloadReason, errors.ErrorInfo(err).GetReason())
+	assert.Equal(t, time.Second, errors.RetryInfo(err).GetRetryDelay().AsDuration())
+
+	// the open streams do not take the capacity of the unary requests
+	critical[0]()
+	sinfo := &grpc.StreamServerInfo{FullMethod: "/helloworld.Greeter/Watch"}
+	entered, release := make(chan struct{}), make(chan struct{})
+	go b.StreamServerInterceptor()(nil, nil, sinfo, func(srv interface{}, stream grpc.ServerStream) error {
+		close(entered)
+		<-release
+		return nil
+	})
+	<-entered
+	assert.Equal(t, int64(19), b.InFlight())
+	_, ok := b.Allow("/helloworld.Greeter/SayHello")
+	assert.True(t, ok)
+	close(release)
+	err = b.StreamServerInterceptor()(nil, nil, &grpc.StreamServerInfo{FullMethod: "/svc/M"}, nil)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestBBRInvalidWindow(t *testing.T) {
+	for _, opt := range []struct {
+		window  time.Duration
+		buckets int
+	}{{time.Second, 0}, {0, 10}, {100, 1000}} {
+		b := NewBBR(WithWindow(opt.window, opt.buckets), WithCPU(func() float64 { return 0 }))
+		assert.Equal(t, defaultBBRWindow/defaultBBRBuckets, b.stats.bucket)
+		done, ok := b.Allow("/svc/M")
+		assert.True(t, ok)
+		done()
+	}
+}
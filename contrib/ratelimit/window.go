@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// SWPair is the limit of the requests in any window of a method.
+type SWPair struct {
+	Method string        // The method name, /package.Service/* or * for every method
+	Limit  int           // The number of requests in a window
+	Window time.Duration // The window size
+	Log    bool          // Log keeps every request time, exact but O(Limit) memory
+}
+
+// SlidingWindowLog allows at most limit requests in any window, it keeps the
+// time of the allowed requests.
+type SlidingWindowLog struct {
+	method string
+	limit  int
+	window time.Duration
+	mu     sync.Mutex
+	times  []time.Time // ring buffer of the allowed requests
+	head   int
+	n      int
+	now    func() time.Time
+}
+
+// NewSlidingWindowLogRL returns a sliding window log limiter of method,
+// it rejects every request when limit or window is not positive.
+func NewSlidingWindowLogRL(limit int, window time.Duration, method string) RateLimiter {
+	if limit < 0 || window <= 0 {
+		limit = 0
+	}
+	return &SlidingWindowLog{
+		method: method,
+		limit:  limit,
+		window: window,
+		times:  make([]time.Time, limit),
+		now:    time.Now,
+	}
+}
+
+func (sw *SlidingWindowLog) Limit() bool {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.limit <= 0 {
+		return false
+	}
+	now := sw.now()
+	// drop the requests out of the window
+	for sw.n > 0 && now.Sub(sw.times[sw.head]) >= sw.window {
+		sw.head = (sw.head + 1) % sw.limit
+		sw.n--
+	}
+	if sw.n == sw.limit {
+		return false
+	}
+	sw.times[(sw.head+sw.n)%sw.limit] = now
+	sw.n++
+	return true
+}
+
+func (sw *SlidingWindowLog) Method() string {
+	return sw.method
+}
+
+// SlidingWindowCounter approximates the sliding window with the counts of the
+// current and the previous fixed windows, the previous count being weighted by
+// its overlap with the sliding window.
+type SlidingWindowCounter struct {
+	method      string
+	limit       int
+	window      time.Duration
+	mu          sync.Mutex
+	start       time.Time // start of the current fixed window
+	prev, count int
+	now         func() time.Time
+}
+
+// NewSlidingWindowCounterRL returns a sliding window counter limiter of method,
+// it rejects every request when limit or window is not positive.
+func NewSlidingWindowCounterRL(limit int, window time.Duration, method string) RateLimiter {
+	if limit < 0 || window <= 0 {
+		limit = 0
+	}
+	return &SlidingWindowCounter{
+		method: method,
+		limit:  limit,
+		window: window,
+		now:    time.Now,
+	}
+}
+
+func (sw *SlidingWindowCounter) Limit() bool {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.limit <= 0 {
+		return false
+	}
+	now := sw.now()
+	if elapsed := now.Sub(sw.start); elapsed >= sw.window {
+		if elapsed < 2*sw.window {
+			sw.prev = sw.count
+		} else {
+			sw.prev = 0
+		}
+		sw.count = 0
+		sw.start = now.Truncate(sw.window)
+	}
+	weight := 1 - float64(now.Sub(sw.start))/float64(sw.window)
+	if float64(sw.prev)*weight+float64(sw.count) >= float64(sw.limit) {
+		return false
+	}
+	sw.count++
+	return true
+}
+
+func (sw *SlidingWindowCounter) Method() string {
+	return sw.method
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlidingWindowLog(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sw := NewSlidingWindowLogRL(2, time.Second, "m").(*SlidingWindowLog)
+	sw.now = func() time.Time { return now }
+
+	assert.True(t, sw.Limit())
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, sw.Limit())
+	assert.False(t, sw.Limit())
+	// the first request leaves the window
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, sw.Limit())
+	assert.False(t, sw.Limit())
+}
+
+func TestSlidingWindowCounter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sw := NewSlidingWindowCounterRL(4, time.Second, "m").(*SlidingWindowCounter)
+	sw.now = func() time.Time { return now }
+
+	for i := 0; i < 4; i++ {
+		assert.True(t, sw.Limit())
+	}
+	assert.False(t, sw.Limit())
+	// a quarter into the next window, the previous window weighs 3
+	now = now.Add(1250 * time.Millisecond)
+	assert.True(t, sw.Limit())
+	assert.False(t, sw.Limit())
+	// two windows later, the previous window is empty
+	now = now.Add(2 * time.Second)
+	for i := 0; i < 4; i++ {
+		assert.True(t, sw.Limit())
+	}
+}
+
+func TestInvalidLimits(t *testing.T) {
+	for name, rl := range map[string]RateLimiter{
+		"log negative limit":     NewSlidingWindowLogRL(-1, time.Second, "m"),
+		"log zero window":        NewSlidingWindowLogRL(1, 0, "m"),
+		"counter negative limit": NewSlidingWindowCounterRL(-1, time.Second, "m"),
+		"counter zero window":    NewSlidingWindowCounterRL(1, 0, "m"),
+		"leaky zero rate":        NewLeakyBucketRL(0, time.Second, "m"),
+		"leaky negative rate":    NewLeakyBucketRL(-1, time.Second, "m"),
+		"concurrency negative":   NewConcurrencyRL(-1, "m"),
+	} {
+		assert.False(t, rl.Limit(), name)
+		assert.False(t, rl.Limit(), name)
+	}
+}
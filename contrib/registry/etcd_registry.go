@@ -3,6 +3,8 @@ package registry
 import (
 	"context"
 	"net"
+	"sync"
+	"sync/atomic"
 
 	"github.com/cockroachdb/errors"
 	clientv3 "go.etcd.io/etcd/client/v3"
@@ -11,9 +13,53 @@ import (
 )
 
 type etcdRegister struct {
-	cli *clientv3.Client
-	ttl int64
-	key string
+	cli         *clientv3.Client
+	ttl         int64
+	key         string
+	service     string
+	onLeaseLost func()
+	closed      atomic.Bool
+}
+
+// Hooks are called with the service name of every registration,
+// server.WithHealth adds them to drive the health status of the servers.
+type Hooks struct {
+	// LeaseLost is called when the lease keepalive is lost
+	LeaseLost func(service string)
+	// Deregister is called when the service is deregistered
+	Deregister func(service string)
+}
+
+var (
+	hooksMu sync.RWMutex
+	hooks   = map[*Hooks]struct{}{}
+)
+
+// AddHooks adds the hooks called by every registration, the returned func
+// removes them.
+func AddHooks(h Hooks) (remove func()) {
+	p := &h
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	hooks[p] = struct{}{}
+	return func() {
+		hooksMu.Lock()
+		defer hooksMu.Unlock()
+		delete(hooks, p)
+	}
+}
+
+// runHooks calls f with every added hooks.
+func runHooks(f func(h *Hooks)) {
+	hooksMu.RLock()
+	hs := make([]*Hooks, 0, len(hooks))
+	for h := range hooks {
+		hs = append(hs, h)
+	}
+	hooksMu.RUnlock()
+	for _, h := range hs {
+		f(h)
+	}
 }
 
 type Option func(*etcdRegister)
@@ -24,6 +70,35 @@ func WithTTL(ttl int64) Option {
 	}
 }
 
+// WithLeaseLostHandler sets f called when the lease keepalive is lost, the
+// servers built with server.WithHealth already stop receiving traffic.
+func WithLeaseLostHandler(f func()) Option {
+	return func(r *etcdRegister) {
+		r.onLeaseLost = f
+	}
+}
+
+// keepAlive consumes the keepalive responses until the lease is lost or deregistered
+func (r *etcdRegister) keepAlive(kresp <-chan *clientv3.LeaseKeepAliveResponse) {
+FLOOP:
+	for v := range kresp {
+		if v == nil {
+			break FLOOP
+		}
+	}
+	if r.closed.Load() {
+		return
+	}
+	runHooks(func(h *Hooks) {
+		if h.LeaseLost != nil {
+			h.LeaseLost(r.service)
+		}
+	})
+	if r.onLeaseLost != nil {
+		r.onLeaseLost()
+	}
+}
+
 // Deprecated: Use [NewEtcdRegister] instead.
 func NewEtcdRegister2(conf clientv3.Config, serviceDesc grpc.ServiceDesc, host, port string, opts ...Option) (*etcdRegister, error) {
 	cli, err := clientv3.New(conf)
@@ -32,8 +107,9 @@ func NewEtcdRegister2(conf clientv3.Config, serviceDesc grpc.ServiceDesc, host,
 	}
 
 	r := &etcdRegister{
-		cli: cli,
-		ttl: 10,
+		cli:     cli,
+		ttl:     10,
+		service: serviceDesc.ServiceName,
 	}
 	for _, o := range opts {
 		o(r)
@@ -63,20 +139,19 @@ func NewEtcdRegister2(conf clientv3.Config, serviceDesc grpc.ServiceDesc, host,
 		return nil, errors.Errorf("etcd keepalive faild, errmsg:%v, lease id:%d", err, resp.ID)
 	}
 
-	go func() {
-	FLOOP:
-		for v := range kresp {
-			if v == nil {
-				break FLOOP
-			}
-		}
-	}()
+	go r.keepAlive(kresp)
 
 	return r, nil
 }
 
 // Close 注销服务
 func (r *etcdRegister) Deregister() error {
+	r.closed.Store(true)
+	runHooks(func(h *Hooks) {
+		if h.Deregister != nil {
+			h.Deregister(r.service)
+		}
+	})
 	if _, err := r.cli.Delete(context.Background(), r.key); err != nil {
 		return err
 	}
@@ -90,8 +165,9 @@ func NewEtcdRegister(conf clientv3.Config, serviceDesc grpc.ServiceDesc, host, p
 	}
 
 	r := &etcdRegister{
-		cli: cli,
-		ttl: 10,
+		cli:     cli,
+		ttl:     10,
+		service: serviceDesc.ServiceName,
 	}
 	for _, o := range opts {
 		o(r)
@@ -123,14 +199,7 @@ func NewEtcdRegister(conf clientv3.Config, serviceDesc grpc.ServiceDesc, host, p
 		return nil, errors.Errorf("etcd keepalive faild, errmsg:%v, lease id:%d", err, resp.ID)
 	}
 
-	go func() {
-	FLOOP:
-		for v := range kresp {
-			if v == nil {
-				break FLOOP
-			}
-		}
-	}()
+	go r.keepAlive(kresp)
 
 	return r, nil
 }
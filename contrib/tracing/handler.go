@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
+	"google.golang.org/grpc/stats"
+)
+
+// DefaultFilter excludes the health checks and the reflection from tracing.
+func DefaultFilter() otelgrpc.Filter {
+	return filters.None(filters.HealthCheck(), filters.ServicePrefix("grpc.reflection."))
+}
+
+// NewServerHandler returns the otelgrpc server stats handler, the rpcs for which
+// filter returns false are neither traced nor measured. A nil filter keeps every rpc.
+func NewServerHandler(filter otelgrpc.Filter, opts ...otelgrpc.Option) stats.Handler {
+	return &filterHandler{Handler: otelgrpc.NewServerHandler(opts...), filter: filter}
+}
+
+// NewClientHandler returns the otelgrpc client stats handler, the rpcs for which
+// filter returns false are neither traced nor measured. A nil filter keeps every rpc.
+func NewClientHandler(filter otelgrpc.Filter, opts ...otelgrpc.Option) stats.Handler {
+	return &filterHandler{Handler: otelgrpc.NewClientHandler(opts...), filter: filter}
+}
+
+type skipKey struct{}
+
+// filterHandler skips the filtered rpcs entirely, otelgrpc.WithFilter only
+// stops recording them, their spans are still started and never ended.
+type filterHandler struct {
+	stats.Handler
+	filter otelgrpc.Filter
+}
+
+func (h *filterHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	if h.filter != nil && !h.filter(info) {
+		return context.WithValue(ctx, skipKey{}, true)
+	}
+	return h.Handler.TagRPC(ctx, info)
+}
+
+func (h *filterHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
+	if skip, _ := ctx.Value(skipKey{}).(bool); skip {
+		return
+	}
+	h.Handler.HandleRPC(ctx, rs)
+}
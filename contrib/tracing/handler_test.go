@@ -0,0 +1,60 @@
+package tracing_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	vp_client "github.com/shenjing023/vivy-polaris/client"
+	"github.com/shenjing023/vivy-polaris/contrib/tracing"
+	"github.com/shenjing023/vivy-polaris/example/pb"
+	vp_server "github.com/shenjing023/vivy-polaris/server"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type greeter struct {
+	pb.UnimplementedGreeterServer
+}
+
+func (greeter) SayHello(ctx context.Context, in *pb.HelloRequest) (*pb.HelloReply, error) {
+	return &pb.HelloReply{Message: "Hello " + in.GetName()}, nil
+}
+
+func TestStatsHandler(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp, shutdown, err := tracing.NewTracerProvider(tracing.WithServiceName("test"), tracing.WithInMemory(exp))
+	assert.Nil(t, err)
+	defer shutdown(context.Background())
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	srv := vp_server.NewServer(vp_server.WithHealth(), vp_server.WithServerTracing(tp))
+	pb.RegisterGreeterServer(srv, greeter{})
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := vp_client.NewClientConn(lis.Addr().String(), vp_client.WithInsecure(), vp_client.WithClientTracing(tp))
+	assert.Nil(t, err)
+	defer conn.Close()
+	_, err = pb.NewGreeterClient(conn).SayHello(context.Background(), &pb.HelloRequest{Name: "world"})
+	assert.Nil(t, err)
+	_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+
+	// the health checks are filtered out by default
+	spans := exp.GetSpans()
+	assert.Len(t, spans, 2)
+	kinds := map[trace.SpanKind]tracetest.SpanStub{}
+	for _, s := range spans {
+		assert.Equal(t, "helloworld.Greeter/SayHello", s.Name)
+		kinds[s.SpanKind] = s
+		// one received and one sent message
+		assert.Len(t, s.Events, 2)
+	}
+	server, client := kinds[trace.SpanKindServer], kinds[trace.SpanKindClient]
+	assert.Equal(t, client.SpanContext.TraceID(), server.SpanContext.TraceID())
+	assert.Equal(t, client.SpanContext.SpanID(), server.Parent.SpanID())
+}
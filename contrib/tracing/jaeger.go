@@ -3,6 +3,7 @@ package tracing
 import (
 	"context"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
@@ -13,6 +14,8 @@ import (
 // the Jaeger exporter that will send spans to the provided url. The returned
 // TracerProvider will also use a Resource configured with all the information
 // about the application.
+//
+// Deprecated: It samples every span, use [NewTracerProvider] instead.
 func NewOTLPTracerProvider(url, serviceName string) (*sdktrace.TracerProvider, error) {
 	// Create the Jaeger exporter
 	// exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(url)))
@@ -26,10 +29,16 @@ func NewOTLPTracerProvider(url, serviceName string) (*sdktrace.TracerProvider, e
 		// Always be sure to batch in production.
 		sdktrace.WithBatcher(exp),
 		// Record information about this application in an Resource.
-		sdktrace.WithResource(resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(serviceName),
-		)),
+		sdktrace.WithResource(NewResource(serviceName)),
 	)
 	return tp, nil
 }
+
+// NewResource returns the Resource describing the application, it is shared
+// by the TracerProvider and the MeterProvider so traces and metrics match.
+func NewResource(serviceName string, attrs ...attribute.KeyValue) *resource.Resource {
+	return resource.NewWithAttributes(
+		semconv.SchemaURL,
+		append([]attribute.KeyValue{semconv.ServiceNameKey.String(serviceName)}, attrs...)...,
+	)
+}
@@ -0,0 +1,152 @@
+package tracing
+
+import (
+	"crypto/tls"
+	"io"
+
+	"github.com/shenjing023/vivy-polaris/options"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type exporterKind int
+
+const (
+	exporterOTLPGRPC exporterKind = iota
+	exporterOTLPHTTP
+	exporterStdout
+	exporterCustom
+)
+
+type tracerOptions struct {
+	serviceName string
+	version     string
+	environment string
+	host        bool
+
+	sampler sdktrace.Sampler
+
+	exporter exporterKind
+	endpoint string
+	writer   io.Writer
+	custom   sdktrace.SpanExporter
+	syncer   bool
+
+	insecure  bool
+	tlsConfig *tls.Config
+	headers   map[string]string
+}
+
+// WithServiceName sets the service.name resource attribute.
+func WithServiceName(name string) options.Option[tracerOptions] {
+	return options.NewFuncOption(func(o *tracerOptions) {
+		o.serviceName = name
+	})
+}
+
+// WithVersion sets the service.version resource attribute.
+func WithVersion(version string) options.Option[tracerOptions] {
+	return options.NewFuncOption(func(o *tracerOptions) {
+		o.version = version
+	})
+}
+
+// WithEnvironment sets the deployment.environment resource attribute, e.g. "production".
+func WithEnvironment(env string) options.Option[tracerOptions] {
+	return options.NewFuncOption(func(o *tracerOptions) {
+		o.environment = env
+	})
+}
+
+// WithHost adds the host.name resource attribute.
+func WithHost() options.Option[tracerOptions] {
+	return options.NewFuncOption(func(o *tracerOptions) {
+		o.host = true
+	})
+}
+
+// WithRatioSampler samples the fraction ratio of the root spans,
+// the child spans follow the parent decision.
+func WithRatioSampler(ratio float64) options.Option[tracerOptions] {
+	return options.NewFuncOption(func(o *tracerOptions) {
+		o.sampler = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
+	})
+}
+
+// WithRateLimitedSampler samples at most perSecond root spans per second,
+// the child spans follow the parent decision.
+func WithRateLimitedSampler(perSecond float64) options.Option[tracerOptions] {
+	return options.NewFuncOption(func(o *tracerOptions) {
+		o.sampler = sdktrace.ParentBased(NewRateLimitedSampler(perSecond))
+	})
+}
+
+// WithSampler sets a custom sampler.
+func WithSampler(s sdktrace.Sampler) options.Option[tracerOptions] {
+	return options.NewFuncOption(func(o *tracerOptions) {
+		o.sampler = s
+	})
+}
+
+// WithOTLPGRPC exports the spans to the OTLP collector over grpc, it is the default exporter.
+func WithOTLPGRPC(endpoint string) options.Option[tracerOptions] {
+	return options.NewFuncOption(func(o *tracerOptions) {
+		o.exporter = exporterOTLPGRPC
+		o.endpoint = endpoint
+	})
+}
+
+// WithOTLPHTTP exports the spans to the OTLP collector over http.
+func WithOTLPHTTP(endpoint string) options.Option[tracerOptions] {
+	return options.NewFuncOption(func(o *tracerOptions) {
+		o.exporter = exporterOTLPHTTP
+		o.endpoint = endpoint
+	})
+}
+
+// WithStdout writes the spans to w in pretty printed json, e.g. for local development.
+func WithStdout(w io.Writer) options.Option[tracerOptions] {
+	return options.NewFuncOption(func(o *tracerOptions) {
+		o.exporter = exporterStdout
+		o.writer = w
+	})
+}
+
+// WithInMemory keeps the spans in exp, they are exported synchronously so
+// tests can assert on exp.GetSpans() right after the span ends.
+func WithInMemory(exp *tracetest.InMemoryExporter) options.Option[tracerOptions] {
+	return options.NewFuncOption(func(o *tracerOptions) {
+		o.exporter = exporterCustom
+		o.custom = exp
+		o.syncer = true
+	})
+}
+
+// WithExporter exports the spans with a custom exporter in batches.
+func WithExporter(exp sdktrace.SpanExporter) options.Option[tracerOptions] {
+	return options.NewFuncOption(func(o *tracerOptions) {
+		o.exporter = exporterCustom
+		o.custom = exp
+	})
+}
+
+// WithInsecure disables the transport security of the OTLP exporters.
+func WithInsecure() options.Option[tracerOptions] {
+	return options.NewFuncOption(func(o *tracerOptions) {
+		o.insecure = true
+	})
+}
+
+// WithTLS sets the tls config of the OTLP exporters.
+func WithTLS(conf *tls.Config) options.Option[tracerOptions] {
+	return options.NewFuncOption(func(o *tracerOptions) {
+		o.tlsConfig = conf
+	})
+}
+
+// WithHeaders sets the headers sent by the OTLP exporters, e.g. the auth token of the collector.
+func WithHeaders(headers map[string]string) options.Option[tracerOptions] {
+	return options.NewFuncOption(func(o *tracerOptions) {
+		o.headers = headers
+	})
+}
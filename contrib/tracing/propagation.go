@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Format is a trace context propagation format
+type Format string
+
+const (
+	W3C     Format = "tracecontext" // W3C Trace Context
+	Baggage Format = "baggage"      // W3C Baggage
+	B3      Format = "b3"           // B3 single header
+	B3Multi Format = "b3multi"      // B3 multiple headers
+	Jaeger  Format = "jaeger"       // uber-trace-id header
+)
+
+// NewPropagator returns a propagator injecting and extracting every format,
+// the W3C Trace Context and Baggage are used if formats is empty.
+func NewPropagator(formats ...Format) propagation.TextMapPropagator {
+	if len(formats) == 0 {
+		formats = []Format{W3C, Baggage}
+	}
+	props := make([]propagation.TextMapPropagator, 0, len(formats))
+	for _, f := range formats {
+		switch f {
+		case W3C:
+			props = append(props, propagation.TraceContext{})
+		case Baggage:
+			props = append(props, propagation.Baggage{})
+		case B3:
+			props = append(props, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case B3Multi:
+			props = append(props, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case Jaeger:
+			props = append(props, jaeger.Jaeger{})
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(props...)
+}
+
+// SetGlobal sets the global TracerProvider and propagator, the instrumentations
+// of the framework never set them, call it only if other libraries rely on the globals.
+func SetGlobal(tp trace.TracerProvider, p propagation.TextMapPropagator) {
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(p)
+}
@@ -0,0 +1,157 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+	"github.com/shenjing023/vivy-polaris/options"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/credentials"
+)
+
+// NewTracerProvider returns a TracerProvider configured by opts, by default it
+// samples every span and exports them to localhost:4317 over OTLP grpc.
+// The returned shutdown function flushes the pending spans and stops the provider.
+func NewTracerProvider(opts ...options.Option[tracerOptions]) (*sdktrace.TracerProvider, func(context.Context) error, error) {
+	o := tracerOptions{
+		sampler: sdktrace.ParentBased(sdktrace.AlwaysSample()),
+	}
+	for _, opt := range opts {
+		opt.Apply(&o)
+	}
+	exp, err := newExporter(&o)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := newResource(&o)
+	if err != nil {
+		return nil, nil, err
+	}
+	export := sdktrace.WithBatcher(exp)
+	if o.syncer {
+		export = sdktrace.WithSyncer(exp)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(o.sampler),
+		export,
+		sdktrace.WithResource(res),
+	)
+	shutdown := func(ctx context.Context) error {
+		return errors.CombineErrors(tp.ForceFlush(ctx), tp.Shutdown(ctx))
+	}
+	return tp, shutdown, nil
+}
+
+func newExporter(o *tracerOptions) (sdktrace.SpanExporter, error) {
+	ctx := context.Background()
+	switch o.exporter {
+	case exporterOTLPHTTP:
+		opts := []otlptracehttp.Option{}
+		if o.endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(o.endpoint))
+		}
+		if o.insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if o.tlsConfig != nil {
+			opts = append(opts, otlptracehttp.WithTLSClientConfig(o.tlsConfig))
+		}
+		if len(o.headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(o.headers))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	case exporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(o.writer), stdouttrace.WithPrettyPrint())
+	case exporterCustom:
+		if o.custom == nil {
+			return nil, errors.New("tracing: nil exporter")
+		}
+		return o.custom, nil
+	default:
+		opts := []otlptracegrpc.Option{}
+		if o.endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(o.endpoint))
+		}
+		if o.insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if o.tlsConfig != nil {
+			opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(o.tlsConfig)))
+		}
+		if len(o.headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(o.headers))
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	}
+}
+
+// NewResourceWithOptions returns the Resource of NewTracerProvider with the
+// same opts, only the resource options are used. It is passed to
+// metrics.NewOTLPMeterProvider so traces and metrics share the attributes.
+func NewResourceWithOptions(opts ...options.Option[tracerOptions]) (*resource.Resource, error) {
+	var o tracerOptions
+	for _, opt := range opts {
+		opt.Apply(&o)
+	}
+	return newResource(&o)
+}
+
+func newResource(o *tracerOptions) (*resource.Resource, error) {
+	var attrs []attribute.KeyValue
+	if o.version != "" {
+		attrs = append(attrs, semconv.ServiceVersionKey.String(o.version))
+	}
+	if o.environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironmentKey.String(o.environment))
+	}
+	if o.host {
+		host, err := resource.New(context.Background(), resource.WithHost())
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, host.Attributes()...)
+	}
+	return NewResource(o.serviceName, attrs...), nil
+}
+
+// rateLimitedSampler samples at most a fixed number of spans per second
+type rateLimitedSampler struct {
+	limiter     *rate.Limiter
+	description string
+}
+
+// NewRateLimitedSampler returns a Sampler sampling at most perSecond spans per second.
+func NewRateLimitedSampler(perSecond float64) sdktrace.Sampler {
+	burst := int(perSecond)
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimitedSampler{
+		limiter:     rate.NewLimiter(rate.Limit(perSecond), burst),
+		description: fmt.Sprintf("RateLimitedSampler{%g}", perSecond),
+	}
+}
+
+func (s *rateLimitedSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	decision := sdktrace.Drop
+	if s.limiter.Allow() {
+		decision = sdktrace.RecordAndSample
+	}
+	return sdktrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (s *rateLimitedSampler) Description() string {
+	return s.description
+}
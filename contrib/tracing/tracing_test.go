@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+func TestNewTracerProvider(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp, shutdown, err := NewTracerProvider(
+		WithServiceName("test"),
+		WithVersion("v1.0.0"),
+		WithEnvironment("testing"),
+		WithRateLimitedSampler(1),
+		WithInMemory(exp),
+	)
+	assert.Nil(t, err)
+
+	tracer := tp.Tracer("test")
+	for i := 0; i < 3; i++ {
+		_, span := tracer.Start(context.Background(), "span")
+		span.End()
+	}
+	// the in-memory exporter is synchronous, spans are kept until shutdown
+	spans := exp.GetSpans()
+	// the bucket holds one token, the other spans are dropped
+	assert.Len(t, spans, 1)
+	attrs := spans[0].Resource.Attributes()
+	assert.Contains(t, attrs, semconv.ServiceNameKey.String("test"))
+	assert.Contains(t, attrs, semconv.ServiceVersionKey.String("v1.0.0"))
+	assert.Contains(t, attrs, semconv.DeploymentEnvironmentKey.String("testing"))
+	assert.Nil(t, shutdown(context.Background()))
+}
+
+func TestNewPropagator(t *testing.T) {
+	assert.ElementsMatch(t, []string{"traceparent", "tracestate", "baggage"}, NewPropagator().Fields())
+	fields := NewPropagator(W3C, B3, Jaeger).Fields()
+	assert.Contains(t, fields, "traceparent")
+	assert.Contains(t, fields, "b3")
+	assert.Contains(t, fields, "uber-trace-id")
+}
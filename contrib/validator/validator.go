@@ -7,48 +7,99 @@ package validator
 import (
 	"context"
 
+	"github.com/shenjing023/vivy-polaris/errors"
+	"github.com/shenjing023/vivy-polaris/errors/errcode"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
+// Reason is the ErrorInfo reason of the invalid messages
+const Reason = "VALIDATION_FAILED"
+
 type validator interface {
 	Validate() error
 	ValidateAll() error
 }
 
+// validate checks msg if it implements validator,
+// all==true return all fields error, otherwise return first error
+func validate(msg interface{}, all bool) error {
+	switch v := msg.(type) {
+	case validator:
+		if all {
+			if err := v.ValidateAll(); err != nil {
+				return invalidErr(err)
+			}
+		} else {
+			if err := v.Validate(); err != nil {
+				return invalidErr(err)
+			}
+		}
+	}
+	return nil
+}
+
+func invalidErr(err error) error {
+	return errors.NewServiceErr(codes.InvalidArgument, err).
+		WithErrorInfo(Reason, errcode.Domain, nil).GRPCStatus().Err()
+}
+
 func UnaryServerInterceptor(all bool) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		switch v := req.(type) {
-		case validator:
-			if all {
-				if err := v.ValidateAll(); err != nil {
-					return nil, status.Error(codes.InvalidArgument, err.Error())
-				}
-			} else {
-				if err := v.Validate(); err != nil {
-					return nil, status.Error(codes.InvalidArgument, err.Error())
-				}
-			}
+		if err := validate(req, all); err != nil {
+			return nil, err
 		}
 		return handler(ctx, req)
 	}
 }
 
+// StreamServerInterceptor validates every message received on the stream.
+func StreamServerInterceptor(all bool) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &recvWrapper{ServerStream: stream, all: all})
+	}
+}
+
+type recvWrapper struct {
+	grpc.ServerStream
+	all bool
+}
+
+func (s *recvWrapper) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return validate(m, s.all)
+}
+
 func UnaryClientInterceptor(all bool) grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		switch v := req.(type) {
-		case validator:
-			if all {
-				if err := v.ValidateAll(); err != nil {
-					return status.Error(codes.InvalidArgument, err.Error())
-				}
-			} else {
-				if err := v.Validate(); err != nil {
-					return status.Error(codes.InvalidArgument, err.Error())
-				}
-			}
+		if err := validate(req, all); err != nil {
+			return err
 		}
 		return invoker(ctx, method, req, reply, cc, opts...)
 	}
 }
+
+// StreamClientInterceptor validates every message sent on the stream.
+func StreamClientInterceptor(all bool) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &sendWrapper{ClientStream: cs, all: all}, nil
+	}
+}
+
+type sendWrapper struct {
+	grpc.ClientStream
+	all bool
+}
+
+func (s *sendWrapper) SendMsg(m interface{}) error {
+	if err := validate(m, s.all); err != nil {
+		return err
+	}
+	return s.ClientStream.SendMsg(m)
+}
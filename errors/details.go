@@ -0,0 +1,77 @@
+package errors
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// WithDetails returns a copy of the error with details attached, they are sent
+// through the status details. e is left unchanged so sentinel errors can be decorated.
+func (e *Error) WithDetails(details ...protoadapt.MessageV1) *Error {
+	c := *e
+	c.Details = append(append(make([]protoadapt.MessageV1, 0, len(e.Details)+len(details)), e.Details...), details...)
+	return &c
+}
+
+// WithBadRequest attaches the fields violations.
+func (e *Error) WithBadRequest(violations ...*errdetails.BadRequest_FieldViolation) *Error {
+	return e.WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+}
+
+// WithErrorInfo attaches the reason, the domain and the metadata of the error.
+func (e *Error) WithErrorInfo(reason, domain string, metadata map[string]string) *Error {
+	return e.WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: domain, Metadata: metadata})
+}
+
+// WithRetryInfo tells the client how long to wait before retrying.
+func (e *Error) WithRetryInfo(delay time.Duration) *Error {
+	return e.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+}
+
+// WithLocalizedMessage attaches a message in the locale, e.g. "en-US", "zh-CN".
+func (e *Error) WithLocalizedMessage(locale, msg string) *Error {
+	return e.WithDetails(&errdetails.LocalizedMessage{Locale: locale, Message: msg})
+}
+
+// FindDetail returns the first detail of type T in the status of err.
+func FindDetail[T protoadapt.MessageV1](err error) (T, bool) {
+	var zero T
+	st, ok := status.FromError(err)
+	if !ok {
+		return zero, false
+	}
+	for _, d := range st.Details() {
+		if v, ok := d.(T); ok {
+			return v, true
+		}
+	}
+	return zero, false
+}
+
+// BadRequest returns the BadRequest detail of err, nil if not found.
+func BadRequest(err error) *errdetails.BadRequest {
+	d, _ := FindDetail[*errdetails.BadRequest](err)
+	return d
+}
+
+// ErrorInfo returns the ErrorInfo detail of err, nil if not found.
+func ErrorInfo(err error) *errdetails.ErrorInfo {
+	d, _ := FindDetail[*errdetails.ErrorInfo](err)
+	return d
+}
+
+// RetryInfo returns the RetryInfo detail of err, nil if not found.
+func RetryInfo(err error) *errdetails.RetryInfo {
+	d, _ := FindDetail[*errdetails.RetryInfo](err)
+	return d
+}
+
+// LocalizedMessage returns the LocalizedMessage detail of err, nil if not found.
+func LocalizedMessage(err error) *errdetails.LocalizedMessage {
+	d, _ := FindDetail[*errdetails.LocalizedMessage](err)
+	return d
+}
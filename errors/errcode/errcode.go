@@ -0,0 +1,210 @@
+// Package errcode registers the application error codes. The application code
+// travels in the ErrorInfo detail instead of overloading the grpc code, so the
+// server and the client resolve the same registry.
+package errcode
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/shenjing023/vivy-polaris/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// MetadataKey is the ErrorInfo metadata key of the application code
+	MetadataKey = "code"
+)
+
+var (
+	// Domain is the ErrorInfo domain of the registered codes
+	Domain = "vivy-polaris"
+
+	// DefaultRegistry is the registry of the package level functions
+	DefaultRegistry = NewRegistry()
+)
+
+// Code is an application error code.
+type Code struct {
+	Value      int32      // application code, e.g. pb.Code_ERROR1
+	Name       string     // unique name, sent as the ErrorInfo reason
+	Message    string     // default message
+	HTTPStatus int        // http status used by the gateways
+	GRPCCode   codes.Code // grpc code sent on the wire
+
+	r *Registry // registry resolving the code in Is
+}
+
+// FromEnum returns a Code with the value and the name of the proto enum value e.
+func FromEnum(e protoreflect.Enum, grpcCode codes.Code, httpStatus int, msg string) Code {
+	return Code{
+		Value:      int32(e.Number()),
+		Name:       string(e.Descriptor().Values().ByNumber(e.Number()).Name()),
+		Message:    msg,
+		HTTPStatus: httpStatus,
+		GRPCCode:   grpcCode,
+	}
+}
+
+// Registry holds the registered codes by value and by name.
+type Registry struct {
+	mu      sync.RWMutex
+	byValue map[int32]*Code
+	byName  map[string]*Code
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		byValue: map[int32]*Code{},
+		byName:  map[string]*Code{},
+	}
+}
+
+// Register registers c in the DefaultRegistry, see Registry.Register.
+func Register(c Code) *Code {
+	return DefaultRegistry.Register(c)
+}
+
+// Lookup returns the code of value registered in the DefaultRegistry.
+func Lookup(value int32) (*Code, bool) {
+	return DefaultRegistry.Lookup(value)
+}
+
+// LookupName returns the code of name registered in the DefaultRegistry.
+func LookupName(name string) (*Code, bool) {
+	return DefaultRegistry.LookupName(name)
+}
+
+// FromError resolves the code carried by err in the DefaultRegistry.
+func FromError(err error) (*Code, bool) {
+	return DefaultRegistry.FromError(err)
+}
+
+// Reset removes every code of the DefaultRegistry, it is meant for tests.
+func Reset() {
+	DefaultRegistry.Reset()
+}
+
+// Register registers c, registering the same value and name again returns the
+// code registered first. It panics if either the value or the name is already
+// registered with another name or value.
+// The zero HTTPStatus is derived from the grpc code.
+func (r *Registry) Register(c Code) *Code {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.byValue[c.Value]; ok {
+		if p.Name == c.Name {
+			return p
+		}
+		panic(fmt.Sprintf("errcode: code %d already registered as %s", c.Value, p.Name))
+	}
+	if p, ok := r.byName[c.Name]; ok {
+		panic(fmt.Sprintf("errcode: code name %s already registered as %d", c.Name, p.Value))
+	}
+	if c.HTTPStatus == 0 {
+		c.HTTPStatus = HTTPStatus(c.GRPCCode)
+	}
+	c.r = r
+	p := &c
+	r.byValue[c.Value] = p
+	r.byName[c.Name] = p
+	return p
+}
+
+// Lookup returns the registered code of value.
+func (r *Registry) Lookup(value int32) (*Code, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.byValue[value]
+	return c, ok
+}
+
+// LookupName returns the registered code of name.
+func (r *Registry) LookupName(name string) (*Code, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.byName[name]
+	return c, ok
+}
+
+// Reset removes every registered code.
+func (r *Registry) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byValue = map[int32]*Code{}
+	r.byName = map[string]*Code{}
+}
+
+// FromError resolves the registered code carried by the ErrorInfo of err,
+// by the numeric code first then by the reason.
+func (r *Registry) FromError(err error) (*Code, bool) {
+	info := errors.ErrorInfo(err)
+	if info == nil || info.GetDomain() != Domain {
+		return nil, false
+	}
+	if v, ok := info.GetMetadata()[MetadataKey]; ok {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err == nil {
+			if c, ok := r.Lookup(int32(n)); ok {
+				return c, true
+			}
+		}
+	}
+	return r.LookupName(info.GetReason())
+}
+
+// New returns an *errors.Error of the code with the default message.
+func (c *Code) New() *errors.Error {
+	return c.Wrap(fmt.Errorf("%s", c.Message))
+}
+
+// Wrap returns an *errors.Error of the code with err as the message.
+func (c *Code) Wrap(err error) *errors.Error {
+	return errors.NewServiceErr(c.GRPCCode, err).
+		WithErrorInfo(c.Name, Domain, map[string]string{MetadataKey: strconv.Itoa(int(c.Value))})
+}
+
+// Is reports whether err carries the code c, resolved in the registry of c.
+func (c *Code) Is(err error) bool {
+	r := c.r
+	if r == nil {
+		r = DefaultRegistry
+	}
+	code, ok := r.FromError(err)
+	return ok && code.Value == c.Value && code.Name == c.Name
+}
+
+// HTTPStatus maps a grpc code to the http status,
+// see https://github.com/googleapis/googleapis/blob/master/google/rpc/code.proto
+func HTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,58 @@
+package errcode
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	er "github.com/shenjing023/vivy-polaris/errors"
+	"github.com/shenjing023/vivy-polaris/example/pb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+func TestCode(t *testing.T) {
+	t.Cleanup(Reset)
+	c1 := Register(FromEnum(pb.Code_ERROR1, codes.FailedPrecondition, 0, "error1"))
+	c2 := Register(FromEnum(pb.Code_ERROR2, codes.NotFound, http.StatusGone, "error2"))
+	assert.Equal(t, "ERROR1", c1.Name)
+	assert.Equal(t, http.StatusBadRequest, c1.HTTPStatus)
+	assert.Equal(t, http.StatusGone, c2.HTTPStatus)
+	assert.Same(t, c1, Register(FromEnum(pb.Code_ERROR1, codes.FailedPrecondition, 0, "error1")))
+	assert.Panics(t, func() { Register(Code{Value: c1.Value, Name: "OTHER"}) })
+	assert.Panics(t, func() { Register(Code{Value: 1000, Name: c1.Name}) })
+
+	// server side error to the client side error
+	err := er.GRPCErr2ServiceErr(er.ServiceErr2GRPCErr(c1.Wrap(errors.New("test error"))))
+	assert.Equal(t, codes.FailedPrecondition, er.Convert(err).Code())
+	assert.Equal(t, "test error", er.Convert(err).Message())
+	c, ok := FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, c1, c)
+	assert.True(t, c1.Is(err))
+	assert.False(t, c2.Is(err))
+	assert.True(t, errors.Is(err, c1.New()))
+
+	_, ok = FromError(errors.New("plain"))
+	assert.False(t, ok)
+
+	// unknown numeric code resolved by the name
+	err = er.NewServiceErr(codes.NotFound, errors.New("gone")).
+		WithErrorInfo(c2.Name, Domain, map[string]string{MetadataKey: "1000"})
+	c, ok = FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, c2, c)
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	c := r.Register(Code{Value: 1, Name: "ONE", GRPCCode: codes.NotFound})
+	assert.Equal(t, http.StatusNotFound, c.HTTPStatus)
+	_, ok := Lookup(1)
+	assert.False(t, ok)
+	assert.True(t, c.Is(c.New()))
+
+	r.Reset()
+	_, ok = r.LookupName("ONE")
+	assert.False(t, ok)
+}
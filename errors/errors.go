@@ -8,11 +8,13 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
 )
 
 type Error struct {
-	Code codes.Code
-	Err  error
+	Code    codes.Code
+	Err     error
+	Details []protoadapt.MessageV1
 }
 
 const (
@@ -27,23 +29,37 @@ func (e *Error) Format(s fmt.State, verb rune) {
 	errors.FormatError(e, s, verb)
 }
 
+// GRPCStatus returns the status with the details attached,
+// it makes status.FromError work with *Error.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(e.Code, e.Err.Error())
+	if len(e.Details) == 0 {
+		return st
+	}
+	ds, err := st.WithDetails(e.Details...)
+	if err != nil {
+		return st
+	}
+	return ds
+}
+
 // ServiceErr2GRPCErr serviceErr covert to GRPCErr
 func ServiceErr2GRPCErr(err error) error {
 	if err == nil {
 		return nil
 	}
 	if e, ok := errors.Cause(err).(*Error); ok {
-		return status.Error(e.Code, e.Err.Error())
+		return e.GRPCStatus().Err()
 	}
 	return status.Error(codes.Unknown, err.Error())
 }
 
-func NewServiceErr(code codes.Code, err error) *Error {
-	return &Error{code, err}
+func NewServiceErr(code codes.Code, err error, details ...protoadapt.MessageV1) *Error {
+	return &Error{Code: code, Err: err, Details: details}
 }
 
 func NewInternalError() *Error {
-	return &Error{codes.Internal, fmt.Errorf(InternalError)}
+	return &Error{Code: codes.Internal, Err: fmt.Errorf(InternalError)}
 }
 
 // GRPCErr2GQLErr grpc error convert to gql_service error
@@ -85,20 +101,78 @@ func ServerErrorInterceptor(ctx context.Context, req interface{},
 	return resp, ServiceErr2GRPCErr(err)
 }
 
-// func ClientErrorInterceptor(ctx context.Context, method string, req, reply interface{},
-// 	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-// 	err := invoker(ctx, method, req, reply, cc, opts...)
-// 	if err == nil {
-// 		return nil
-// 	}
-// 	cause := errors.Cause(err)
-// 	st, ok := status.FromError(cause)
-// 	if ok {
-// 		// details := st.Details()
+// ServerErrorStreamInterceptor transfer a stream handler error to status error
+func ServerErrorStreamInterceptor(srv interface{}, stream grpc.ServerStream,
+	info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return ServiceErr2GRPCErr(handler(srv, stream))
+}
 
-// 	}
-// 	return err
-// }
+// Is reports whether target is a *Error with the same code, the reason of
+// ErrorInfo is compared if target carries one, otherwise the message.
+// It allows errors.Is against sentinel service errors on both sides.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok || t.Code != e.Code {
+		return false
+	}
+	if info := ErrorInfo(t); info != nil {
+		return ErrorInfo(e).GetReason() == info.GetReason()
+	}
+	if t.Err == nil || e.Err == nil {
+		return t.Err == e.Err
+	}
+	return t.Err.Error() == e.Err.Error()
+}
+
+// GRPCErr2ServiceErr rebuilds the *Error from a status error with the code,
+// the message and the decoded details. err is returned as is if it is not a status error.
+func GRPCErr2ServiceErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	e := &Error{Code: st.Code(), Err: errors.New(st.Message())}
+	for _, d := range st.Details() {
+		if m, ok := d.(protoadapt.MessageV1); ok {
+			e.Details = append(e.Details, m)
+		}
+	}
+	return e
+}
+
+// ClientErrorInterceptor transfer a status error to *Error
+func ClientErrorInterceptor(ctx context.Context, method string, req, reply interface{},
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return GRPCErr2ServiceErr(invoker(ctx, method, req, reply, cc, opts...))
+}
+
+// ClientErrorStreamInterceptor transfer the status errors of a stream to *Error
+func ClientErrorStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+	method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		return nil, GRPCErr2ServiceErr(err)
+	}
+	return &clientStream{ClientStream: cs}, nil
+}
+
+type clientStream struct {
+	grpc.ClientStream
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	return GRPCErr2ServiceErr(s.ClientStream.SendMsg(m))
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	return GRPCErr2ServiceErr(s.ClientStream.RecvMsg(m))
+}
 
 func Convert(err error) *status.Status {
 	s, _ := status.FromError(err)
@@ -1,12 +1,18 @@
 package errors
 
 import (
+	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"errors"
 
 	erro "github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	_ "unsafe"
 )
@@ -32,3 +38,80 @@ func b() error {
 func c() error {
 	return erro.New("c")
 }
+
+func TestDetails(t *testing.T) {
+	err := NewServiceErr(codes.InvalidArgument, erro.New("invalid name")).
+		WithBadRequest(&errdetails.BadRequest_FieldViolation{Field: "name", Description: "empty"}).
+		WithErrorInfo("EMPTY_NAME", "example.com", map[string]string{"field": "name"}).
+		WithRetryInfo(time.Second).
+		WithLocalizedMessage("zh-CN", "名字不能为空")
+	gErr := ServiceErr2GRPCErr(err)
+
+	assert.Equal(t, codes.InvalidArgument, Convert(gErr).Code())
+	assert.Equal(t, "name", BadRequest(gErr).GetFieldViolations()[0].GetField())
+	assert.Equal(t, "EMPTY_NAME", ErrorInfo(gErr).GetReason())
+	assert.Equal(t, "name", ErrorInfo(gErr).GetMetadata()["field"])
+	assert.Equal(t, time.Second, RetryInfo(gErr).GetRetryDelay().AsDuration())
+	assert.Equal(t, "名字不能为空", LocalizedMessage(gErr).GetMessage())
+	assert.Nil(t, BadRequest(erro.New("plain")))
+
+	sentinel := NewServiceErr(codes.NotFound, erro.New("not found"))
+	_ = sentinel.WithRetryInfo(time.Second)
+	assert.Empty(t, sentinel.Details)
+}
+
+func TestGRPCErr2ServiceErr(t *testing.T) {
+	errNotFound := NewServiceErr(codes.NotFound, erro.New("user not found"))
+	errReason := NewServiceErr(codes.NotFound, erro.New("not found")).WithErrorInfo("USER_NOT_FOUND", "example.com", nil)
+
+	err := GRPCErr2ServiceErr(ServiceErr2GRPCErr(errNotFound))
+	var se *Error
+	assert.True(t, errors.As(err, &se))
+	assert.Equal(t, codes.NotFound, se.Code)
+	assert.True(t, errors.Is(err, errNotFound))
+	assert.False(t, errors.Is(err, errReason))
+
+	err = GRPCErr2ServiceErr(ServiceErr2GRPCErr(NewServiceErr(codes.NotFound, erro.New("other message")).
+		WithErrorInfo("USER_NOT_FOUND", "example.com", nil)))
+	assert.True(t, errors.Is(err, errReason))
+	assert.Equal(t, "USER_NOT_FOUND", ErrorInfo(err).GetReason())
+
+	plain := erro.New("plain")
+	assert.Equal(t, plain, GRPCErr2ServiceErr(plain))
+}
+
+type allowedErr struct{}
+
+func (allowedErr) Error() string { return "allowed" }
+
+func TestSanitize(t *testing.T) {
+	s := NewSanitizer(AllowType[allowedErr]())
+	ctx := context.Background()
+
+	err := s.Sanitize(ctx, "/test", erro.New("dial tcp 10.0.0.1:3306: connection refused"))
+	st := Convert(err)
+	assert.Equal(t, codes.Internal, st.Code())
+	assert.Equal(t, InternalError, st.Message())
+	assert.NotEmpty(t, CorrelationID(err))
+
+	err = s.Sanitize(ctx, "/test", erro.Wrap(allowedErr{}, "wrapped"))
+	assert.Equal(t, codes.Unknown, Convert(err).Code())
+	assert.Equal(t, "wrapped: allowed", Convert(err).Message())
+
+	err = s.Sanitize(ctx, "/test", NewServiceErr(codes.NotFound, erro.New("not found")))
+	assert.Equal(t, codes.NotFound, Convert(err).Code())
+
+	err = s.Sanitize(ctx, "/test", context.DeadlineExceeded)
+	assert.Equal(t, codes.DeadlineExceeded, Convert(err).Code())
+
+	downstream := status.Error(codes.Unavailable, "dial 10.0.0.3:5432")
+	err = s.Sanitize(ctx, "/test", downstream)
+	assert.Equal(t, codes.Unavailable, Convert(err).Code())
+	for _, wrapped := range []error{erro.Wrap(downstream, "query"), fmt.Errorf("query: %w", downstream)} {
+		err = s.Sanitize(ctx, "/test", wrapped)
+		st = Convert(err)
+		assert.Equal(t, codes.Internal, st.Code())
+		assert.Equal(t, InternalError, st.Message())
+		assert.NotEmpty(t, CorrelationID(err))
+	}
+}
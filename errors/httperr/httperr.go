@@ -0,0 +1,215 @@
+// Package httperr translates grpc status errors to RFC 7807 problem responses
+// for the http edge services.
+package httperr
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shenjing023/vivy-polaris/errors"
+	"github.com/shenjing023/vivy-polaris/errors/errcode"
+	"github.com/shenjing023/vivy-polaris/options"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// ContentType is the media type of the problem response
+const ContentType = "application/problem+json"
+
+// Problem is the RFC 7807 problem details, extended with the error code
+// and the status details.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Code     string            `json:"code"`
+	Details  []json.RawMessage `json:"details,omitempty"`
+}
+
+// Localizer returns the message of st in locale, ok==false falls back to the next localization.
+type Localizer func(locale string, st *status.Status) (msg string, ok bool)
+
+type translatorOptions struct {
+	statusMapping map[codes.Code]int
+	typeURI       string
+	details       bool
+	localizer     Localizer
+}
+
+// Translator translates grpc errors to problems.
+type Translator struct {
+	opts translatorOptions
+}
+
+// WithStatusMapping overrides the http status of the grpc codes,
+// the registered errcode HTTPStatus takes precedence.
+func WithStatusMapping(m map[codes.Code]int) options.Option[translatorOptions] {
+	return options.NewFuncOption(func(o *translatorOptions) {
+		for k, v := range m {
+			o.statusMapping[k] = v
+		}
+	})
+}
+
+// WithTypeURI sets the base uri of the problem type, the code is appended to it.
+// Default type is "about:blank".
+func WithTypeURI(base string) options.Option[translatorOptions] {
+	return options.NewFuncOption(func(o *translatorOptions) {
+		o.typeURI = base
+	})
+}
+
+// WithDetails passes the status details through, default is true.
+// DebugInfo is never passed through.
+func WithDetails(flag bool) options.Option[translatorOptions] {
+	return options.NewFuncOption(func(o *translatorOptions) {
+		o.details = flag
+	})
+}
+
+// WithLocalizer sets the localizer tried before the LocalizedMessage detail.
+func WithLocalizer(l Localizer) options.Option[translatorOptions] {
+	return options.NewFuncOption(func(o *translatorOptions) {
+		o.localizer = l
+	})
+}
+
+func NewTranslator(opts ...options.Option[translatorOptions]) *Translator {
+	t := &Translator{
+		opts: translatorOptions{
+			statusMapping: make(map[codes.Code]int),
+			details:       true,
+		},
+	}
+	for _, opt := range opts {
+		opt.Apply(&t.opts)
+	}
+	return t
+}
+
+// Translate translates err to a problem, the message is localized in locale if possible.
+// Errors which are not status errors are translated to InternalError.
+func (t *Translator) Translate(err error, locale string) *Problem {
+	st, ok := status.FromError(err)
+	if !ok {
+		st = status.New(codes.Internal, errors.InternalError)
+	}
+	p := &Problem{
+		Type:   "about:blank",
+		Status: t.httpStatus(st.Code()),
+		Detail: st.Message(),
+		Code:   st.Code().String(),
+	}
+	if c, ok := errcode.FromError(err); ok {
+		p.Status = c.HTTPStatus
+		p.Code = c.Name
+	}
+	p.Title = http.StatusText(p.Status)
+	if t.opts.typeURI != "" {
+		p.Type = strings.TrimSuffix(t.opts.typeURI, "/") + "/" + p.Code
+	}
+	if msg, ok := t.localize(locale, st); ok {
+		p.Detail = msg
+	}
+	if t.opts.details {
+		for _, d := range st.Details() {
+			if raw, ok := marshalDetail(d); ok {
+				p.Details = append(p.Details, raw)
+			}
+		}
+	}
+	return p
+}
+
+func (t *Translator) httpStatus(code codes.Code) int {
+	if s, ok := t.opts.statusMapping[code]; ok {
+		return s
+	}
+	return errcode.HTTPStatus(code)
+}
+
+func (t *Translator) localize(locale string, st *status.Status) (string, bool) {
+	if locale == "" {
+		return "", false
+	}
+	if t.opts.localizer != nil {
+		if msg, ok := t.opts.localizer(locale, st); ok {
+			return msg, true
+		}
+	}
+	for _, d := range st.Details() {
+		if lm, ok := d.(*errdetails.LocalizedMessage); ok && matchLocale(locale, lm.GetLocale()) {
+			return lm.GetMessage(), true
+		}
+	}
+	return "", false
+}
+
+// matchLocale matches "zh-CN" with "zh-CN" or "zh"
+func matchLocale(want, have string) bool {
+	if strings.EqualFold(want, have) {
+		return true
+	}
+	lang, _, _ := strings.Cut(want, "-")
+	return strings.EqualFold(lang, have)
+}
+
+// marshalDetail marshals the detail with its @type, DebugInfo is dropped.
+func marshalDetail(d any) (json.RawMessage, bool) {
+	m, ok := d.(proto.Message)
+	if !ok {
+		return nil, false
+	}
+	if _, ok := m.(*errdetails.DebugInfo); ok {
+		return nil, false
+	}
+	a, err := anypb.New(m)
+	if err != nil {
+		return nil, false
+	}
+	b, err := protojson.Marshal(a)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// locale returns the first language of the Accept-Language header
+func locale(r *http.Request) string {
+	lang, _, _ := strings.Cut(r.Header.Get("Accept-Language"), ",")
+	lang, _, _ = strings.Cut(lang, ";")
+	return strings.TrimSpace(lang)
+}
+
+// WriteError writes the problem of err to w, localized with the Accept-Language of r.
+func (t *Translator) WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	p := t.Translate(err, locale(r))
+	p.Instance = r.URL.Path
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// GinMiddleware writes the problem of the last error added by c.Error,
+// if the handler has not written the response.
+func (t *Translator) GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		err := c.Errors.Last()
+		if err == nil || c.Writer.Written() {
+			return
+		}
+		p := t.Translate(err.Err, locale(c.Request))
+		p.Instance = c.Request.URL.Path
+		c.Header("Content-Type", ContentType)
+		c.JSON(p.Status, p)
+	}
+}
@@ -0,0 +1,55 @@
+package httperr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	er "github.com/shenjing023/vivy-polaris/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+func TestTranslate(t *testing.T) {
+	tr := NewTranslator(WithTypeURI("https://example.com/problems/"),
+		WithStatusMapping(map[codes.Code]int{codes.FailedPrecondition: http.StatusConflict}))
+	err := er.ServiceErr2GRPCErr(er.NewServiceErr(codes.FailedPrecondition, errors.New("name is taken")).
+		WithLocalizedMessage("zh-CN", "名字已被使用").
+		WithErrorInfo("NAME_TAKEN", "example.com", nil))
+
+	p := tr.Translate(err, "")
+	assert.Equal(t, http.StatusConflict, p.Status)
+	assert.Equal(t, "name is taken", p.Detail)
+	assert.Equal(t, "https://example.com/problems/FailedPrecondition", p.Type)
+	assert.Len(t, p.Details, 2)
+
+	assert.Equal(t, "名字已被使用", tr.Translate(err, "zh-CN").Detail)
+
+	p = NewTranslator().Translate(errors.New("dial tcp: connection refused"), "")
+	assert.Equal(t, http.StatusInternalServerError, p.Status)
+	assert.Equal(t, er.InternalError, p.Detail)
+}
+
+func TestGinMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(NewTranslator().GinMiddleware())
+	engine.GET("/ping", func(c *gin.Context) {
+		c.Error(er.ServiceErr2GRPCErr(er.NewServiceErr(codes.NotFound, errors.New("user not found"))))
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
+	engine.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	var p Problem
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, "user not found", p.Detail)
+	assert.Equal(t, "/ping", p.Instance)
+}
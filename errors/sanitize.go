@@ -0,0 +1,121 @@
+package errors
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/cockroachdb/errors"
+	"github.com/google/uuid"
+	"github.com/shenjing023/vivy-polaris/log"
+	"github.com/shenjing023/vivy-polaris/options"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Sanitizer masks the errors that are not meant for the callers, e.g. raw database
+// or driver errors, to InternalError with a correlation id. The full error is logged
+// server-side with the same correlation id.
+//
+// *Error, unwrapped status errors and context errors always pass through, other errors
+// pass through only if they are allowed by AllowType or AllowFunc.
+type Sanitizer struct {
+	opts sanitizeOptions
+}
+
+type sanitizeOptions struct {
+	allows []func(error) bool
+	logger *slog.Logger
+}
+
+// AllowType allows the errors of type T to pass through with codes.Unknown.
+func AllowType[T error]() options.Option[sanitizeOptions] {
+	return AllowFunc(func(err error) bool {
+		var t T
+		return errors.As(err, &t)
+	})
+}
+
+// AllowFunc allows the errors matched by f to pass through with codes.Unknown.
+func AllowFunc(f func(err error) bool) options.Option[sanitizeOptions] {
+	return options.NewFuncOption(func(o *sanitizeOptions) {
+		o.allows = append(o.allows, f)
+	})
+}
+
+// WithLogger sets the logger of the masked errors, default is slog.Default().
+func WithLogger(l *slog.Logger) options.Option[sanitizeOptions] {
+	return options.NewFuncOption(func(o *sanitizeOptions) {
+		o.logger = l
+	})
+}
+
+func NewSanitizer(opts ...options.Option[sanitizeOptions]) *Sanitizer {
+	s := &Sanitizer{}
+	for _, opt := range opts {
+		opt.Apply(&s.opts)
+	}
+	return s
+}
+
+// Sanitize transfer err to status error, masking it if it is not allowed.
+func (s *Sanitizer) Sanitize(ctx context.Context, method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := errors.Cause(err).(*Error); ok {
+		return ServiceErr2GRPCErr(err)
+	}
+	// a wrapped status error, e.g. of a downstream call, is masked as the
+	// wrapping message and the downstream code are not meant for the callers
+	if _, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	for _, allow := range s.opts.allows {
+		if allow(err) {
+			return ServiceErr2GRPCErr(err)
+		}
+	}
+
+	id := correlationID(ctx)
+	logger := s.opts.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.ErrorContext(ctx, InternalError, "method", method, "correlation_id", id, log.ErrAttr(err))
+	return NewInternalError().WithDetails(&errdetails.RequestInfo{RequestId: id}).GRPCStatus().Err()
+}
+
+// UnaryServerInterceptor sanitizes the errors of the unary handlers
+func (s *Sanitizer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		return resp, s.Sanitize(ctx, info.FullMethod, err)
+	}
+}
+
+// StreamServerInterceptor sanitizes the errors of the stream handlers
+func (s *Sanitizer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return s.Sanitize(stream.Context(), info.FullMethod, handler(srv, stream))
+	}
+}
+
+// CorrelationID returns the correlation id of a masked error, "" if not found.
+func CorrelationID(err error) string {
+	d, _ := FindDetail[*errdetails.RequestInfo](err)
+	return d.GetRequestId()
+}
+
+// correlationID uses the trace id if the request is traced, so the logs
+// and the trace can be found by the id the caller received.
+func correlationID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return uuid.NewString()
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -15,6 +16,8 @@ import (
 	vp_client "github.com/shenjing023/vivy-polaris/client"
 	"github.com/shenjing023/vivy-polaris/contrib/registry"
 	er "github.com/shenjing023/vivy-polaris/errors"
+	"github.com/shenjing023/vivy-polaris/errors/errcode"
+	"github.com/shenjing023/vivy-polaris/errors/httperr"
 	"github.com/shenjing023/vivy-polaris/example/pb"
 	vp_server "github.com/shenjing023/vivy-polaris/server"
 	clientv3 "go.etcd.io/etcd/client/v3"
@@ -29,6 +32,17 @@ var (
 	host = "localhost"
 )
 
+// application error codes, they travel in the ErrorInfo detail
+// instead of overloading the grpc codes
+var (
+	CodeError1 = errcode.Register(errcode.FromEnum(pb.Code_ERROR1, codes.FailedPrecondition, 0, "error1"))
+	CodeError2 = errcode.Register(errcode.FromEnum(pb.Code_ERROR2, codes.NotFound, 0, "error2"))
+)
+
+// ErrPermissionDenied is a sentinel service error, clients built with
+// vp_client.WithClientError can match it with errors.Is
+var ErrPermissionDenied = er.NewServiceErr(codes.PermissionDenied, errors.New("test error1"))
+
 type test_server struct {
 	pb.UnimplementedGreeterServer
 }
@@ -37,9 +51,9 @@ type test_server struct {
 func (s *test_server) SayHello(ctx context.Context, in *pb.HelloRequest) (*pb.HelloReply, error) {
 	log.Printf("Received: %v", in.GetName())
 	return &pb.HelloReply{Message: "Hello " + in.GetName()}, nil
-	// return nil, er.NewServiceErr(codes.Code(pb.Code_ERROR1), errors.New("test error"))
+	// return nil, CodeError1.Wrap(errors.New("test error"))
 	// return nil, errors.New("test error")
-	// return nil, er.NewServiceErr(codes.PermissionDenied, errors.New("test error1"))
+	// return nil, ErrPermissionDenied
 	// return nil, er.NewInternalError()
 }
 
@@ -64,31 +78,29 @@ func TestRegistry() {
 		Endpoints:   []string{"127.0.0.1:2379"},
 		DialTimeout: time.Second * 5,
 	}
-	r, err := registry.NewEtcdRegister(conf, pb.Greeter_ServiceDesc, host, fmt.Sprintf("%d", port))
-	if err != nil {
-		panic(err)
-	}
+	var deregister func() error
 
 	srv := vp_server.NewServer()
 	pb.RegisterGreeterServer(srv, &test_server{})
-	go func() {
-		log.Println("server start")
-		err = srv.Serve(lis)
-		if err != nil {
-			panic(err)
-		}
-	}()
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
-	for a := range c {
-		switch a {
-		case syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT:
+	app := vp_server.NewApp(srv, "", vp_server.WithListener(lis),
+		vp_server.WithAfterStart(func(ctx context.Context) error {
+			r, err := registry.NewEtcdRegister(conf, pb.Greeter_ServiceDesc, host, fmt.Sprintf("%d", port))
+			if err != nil {
+				return err
+			}
+			deregister = r.Deregister
+			return nil
+		}),
+		vp_server.WithBeforeStop(func(ctx context.Context) error {
 			log.Println("退出")
-			log.Println(r.Deregister())
-			return
-		default:
-			return
-		}
+			if deregister == nil {
+				return nil
+			}
+			return deregister()
+		}),
+	)
+	if err := app.Run(); err != nil {
+		log.Printf("failed to run server: %+v", err)
 	}
 }
 
@@ -110,7 +122,7 @@ func InitClient() {
 
 	ClientConn, err = vp_client.NewClientConn(registry.GetServiceTarget(pb.Greeter_ServiceDesc),
 		vp_client.WithEtcdDiscovery(conf, pb.Greeter_ServiceDesc),
-		vp_client.WithInsecure(), vp_client.WithRRLB())
+		vp_client.WithInsecure(), vp_client.WithRRLB(), vp_client.WithClientError())
 	if err != nil {
 		log.Fatalf("net.Connect err: %v", err)
 	}
@@ -119,6 +131,7 @@ func InitClient() {
 func httpServer() {
 	InitClient()
 	engine := gin.Default()
+	engine.Use(httperr.NewTranslator().GinMiddleware())
 	engine.GET("/ping", func(c *gin.Context) {
 		conn := pb.NewGreeterClient(ClientConn)
 
@@ -127,20 +140,21 @@ func httpServer() {
 		defer cancel()
 		r, err := conn.SayHello(ctx, &pb.HelloRequest{Name: "name"})
 		if err != nil {
-			s := er.Convert(err)
-			switch s.Code() {
-			case codes.Code(pb.Code_ERROR1):
-				log.Println(s.Message())
-			case codes.Code(pb.Code_ERROR2):
-				log.Println(s.Message())
-			case codes.PermissionDenied:
-				log.Println(s.Message())
-			case codes.Internal:
-				log.Println(s.Message())
+			var se *er.Error
+			switch {
+			case errors.Is(err, ErrPermissionDenied):
+				log.Println(err)
+			case CodeError1.Is(err), CodeError2.Is(err):
+				log.Println(err)
+			case errors.As(err, &se) && se.Code == codes.Internal:
+				log.Println(se.Err)
 			default:
-				log.Println(s.Code())
+				log.Println(err)
 			}
-			log.Printf("could not greet: %v", s)
+			log.Printf("could not greet: %v", err)
+			// translated to a problem response by the httperr middleware
+			c.Error(err)
+			return
 		}
 		log.Printf("Greeting: %s\n", r.GetMessage())
 		c.JSON(200, gin.H{
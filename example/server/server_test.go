@@ -12,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/shenjing023/vivy-polaris/contrib/accesslog"
 	"github.com/shenjing023/vivy-polaris/contrib/ratelimit"
 	"github.com/shenjing023/vivy-polaris/contrib/registry"
 	"github.com/shenjing023/vivy-polaris/contrib/tracing"
@@ -103,7 +104,7 @@ func TestRateLimit(t *testing.T) {
 
 func TestDebug(t *testing.T) {
 	llog.Init(llog.WithLevel(slog.LevelDebug))
-	srv := vp_server.NewServer(vp_server.WithDebug(true))
+	srv := vp_server.NewServer(vp_server.WithAccessLog(accesslog.NewLogger(accesslog.WithLevel(slog.LevelDebug), accesslog.WithFields(accesslog.Request, accesslog.Response))))
 	pb.RegisterGreeterServer(srv, &test_server{})
 	t.Logf("server listening at %v", lis.Addr())
 	if err := srv.Serve(lis); err != nil {
@@ -124,7 +125,7 @@ func TestTracing(t *testing.T) {
 		}
 	}()
 
-	srv := vp_server.NewServer(vp_server.WithDebug(true), vp_server.WithServerTracing(tp))
+	srv := vp_server.NewServer(vp_server.WithAccessLog(accesslog.NewLogger(accesslog.WithLevel(slog.LevelDebug), accesslog.WithFields(accesslog.Request, accesslog.Response))), vp_server.WithServerTracing(tp))
 	pb.RegisterGreeterServer(srv, &test_server{})
 	t.Logf("server listening at %v", lis.Addr())
 	if err := srv.Serve(lis); err != nil {
@@ -0,0 +1,206 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/netip"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// levelRequest changes the level, or the override of Method or Package when set.
+// Delete removes the override instead.
+type levelRequest struct {
+	Level   string `json:"level"`
+	Method  string `json:"method"`
+	Package string `json:"package"`
+	Delete  bool   `json:"delete"`
+}
+
+func (r levelRequest) apply() error {
+	if r.Delete {
+		switch {
+		case r.Method != "":
+			DeleteMethodLevel(r.Method)
+		case r.Package != "":
+			DeletePackageLevel(r.Package)
+		default:
+			ResetLevels()
+		}
+		return nil
+	}
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(r.Level)); err != nil {
+		return err
+	}
+	switch {
+	case r.Method != "":
+		SetMethodLevel(r.Method, l)
+	case r.Package != "":
+		SetPackageLevel(r.Package, l)
+	default:
+		SetLevel(l)
+	}
+	return nil
+}
+
+// LevelHandler returns the admin http handler of the levels.
+//
+//	GET                                      returns the level and the overrides
+//	PUT {"level":"debug"}                    sets the level
+//	PUT {"level":"debug","method":"/pkg.Svc/M"}  overrides the level of a method, "package" of a package
+//	DELETE {"method":"/pkg.Svc/M"}           removes an override, every override without a body
+//
+// It must be served on an internal port only.
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost, http.MethodDelete:
+			var req levelRequest
+			if r.ContentLength != 0 {
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			req.Delete = r.Method == http.MethodDelete
+			if err := req.apply(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			slog.Info("log level changed", "request", req)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(levels.snapshot())
+	})
+}
+
+// LevelServiceName is the name of the admin grpc service of the levels
+const LevelServiceName = "vivy.log.v1.LogLevel"
+
+// levelServer serves the LogLevel service, the requests and the responses are
+// google.protobuf.Struct with the fields of levelRequest and levelsSnapshot, e.g.
+//
+//	grpcurl -d '{"level":"debug","method":"/helloworld.Greeter/SayHello"}' host:port vivy.log.v1.LogLevel/SetLevel
+type levelServer interface {
+	GetLevel(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	SetLevel(context.Context, *structpb.Struct) (*structpb.Struct, error)
+}
+
+// AuthFunc authorizes a call of the LogLevel service, the returned error is sent
+// to the caller, e.g. status.Error(codes.PermissionDenied, ...).
+type AuthFunc func(ctx context.Context, fullMethod string) error
+
+// AllowPeers authorizes the callers whose address is in one of prefixes,
+// e.g. netip.MustParsePrefix("127.0.0.0/8").
+func AllowPeers(prefixes ...netip.Prefix) AuthFunc {
+	return func(ctx context.Context, fullMethod string) error {
+		p, ok := peer.FromContext(ctx)
+		if ok && p.Addr != nil {
+			if ap, err := netip.ParseAddrPort(p.Addr.String()); err == nil {
+				for _, prefix := range prefixes {
+					if prefix.Contains(ap.Addr().Unmap()) {
+						return nil
+					}
+				}
+			}
+		}
+		return status.Error(codes.PermissionDenied, "log level service: peer not allowed")
+	}
+}
+
+type levelService struct {
+	auth AuthFunc
+}
+
+func (s levelService) authorize(ctx context.Context, method string) error {
+	if s.auth == nil {
+		return status.Error(codes.PermissionDenied, "log level service: no authorization configured")
+	}
+	return s.auth(ctx, "/"+LevelServiceName+"/"+method)
+}
+
+func (s levelService) GetLevel(ctx context.Context, _ *structpb.Struct) (*structpb.Struct, error) {
+	if err := s.authorize(ctx, "GetLevel"); err != nil {
+		return nil, err
+	}
+	return snapshotStruct()
+}
+
+func (s levelService) SetLevel(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	if err := s.authorize(ctx, "SetLevel"); err != nil {
+		return nil, err
+	}
+	b, err := in.MarshalJSON()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	var req levelRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := req.apply(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	slog.InfoContext(ctx, "log level changed", "request", req)
+	return snapshotStruct()
+}
+
+func snapshotStruct() (*structpb.Struct, error) {
+	b, err := json.Marshal(levels.snapshot())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	out := &structpb.Struct{}
+	if err := out.UnmarshalJSON(b); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return out, nil
+}
+
+func levelHandler(name string) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		in := new(structpb.Struct)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		call := srv.(levelServer).GetLevel
+		if name == "SetLevel" {
+			call = srv.(levelServer).SetLevel
+		}
+		if interceptor == nil {
+			return call(ctx, in)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + LevelServiceName + "/" + name}
+		return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return call(ctx, req.(*structpb.Struct))
+		})
+	}
+}
+
+var levelServiceDesc = grpc.ServiceDesc{
+	ServiceName: LevelServiceName,
+	HandlerType: (*levelServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "GetLevel", Handler: levelHandler("GetLevel")},
+		{MethodName: "SetLevel", Handler: levelHandler("SetLevel")},
+	},
+}
+
+// RegisterLevelService registers the admin grpc service of the levels on s,
+// every call is authorized by auth first. The service has no authentication of
+// its own, a nil auth denies every call.
+func RegisterLevelService(s grpc.ServiceRegistrar, auth AuthFunc) {
+	s.RegisterService(&levelServiceDesc, levelService{auth: auth})
+}
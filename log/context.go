@@ -0,0 +1,106 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TraceIDKey is the attribute key of the trace id
+	TraceIDKey = "trace_id"
+	// SpanIDKey is the attribute key of the span id
+	SpanIDKey = "span_id"
+)
+
+type (
+	attrsKey  struct{}
+	loggerKey struct{}
+)
+
+// ContextHandler adds the trace id, the span id and the attributes of
+// ContextWithAttrs found in the context to every record.
+type ContextHandler struct {
+	slog.Handler
+	// ctx is the request context of the logger returned by FromContext,
+	// it is used when the record is logged without a context.
+	ctx context.Context
+	// levels filters the records with the level overrides, nil leaves it to Handler.
+	levels *levelTable
+}
+
+// NewContextHandler returns a ContextHandler wrapping h.
+func NewContextHandler(h slog.Handler) *ContextHandler {
+	if ch, ok := h.(*ContextHandler); ok {
+		return ch
+	}
+	return &ContextHandler{Handler: h}
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.ctx != nil && !hasFields(ctx) {
+		ctx = h.ctx
+	}
+	if ctx != nil {
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			r.AddAttrs(slog.String(TraceIDKey, sc.TraceID().String()), slog.String(SpanIDKey, sc.SpanID().String()))
+		}
+		if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+			r.AddAttrs(attrs...)
+		}
+	}
+	if h.levels != nil && !h.levels.enabled(ctx, r.Level, r.PC) {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs), ctx: h.ctx, levels: h.levels}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name), ctx: h.ctx, levels: h.levels}
+}
+
+// withContext returns a copy of h bound to ctx.
+func (h *ContextHandler) withContext(ctx context.Context) *ContextHandler {
+	return &ContextHandler{Handler: h.Handler, ctx: ctx, levels: h.levels}
+}
+
+// hasFields reports whether ctx carries a span or attributes to log.
+func hasFields(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return true
+	}
+	_, ok := ctx.Value(attrsKey{}).([]slog.Attr)
+	return ok
+}
+
+// ContextWithAttrs returns a copy of ctx carrying attrs, e.g. the method and
+// the peer of the request, they are logged by the ContextHandler.
+func ContextWithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(prev)+len(attrs))
+	merged = append(append(merged, prev...), attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// NewContext returns a copy of ctx carrying l, retrieved by FromContext.
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the request-scoped logger of ctx. Without one, the default
+// logger is returned bound to ctx, so the trace id, the span id and the
+// attributes of ctx are logged even by the methods without a context.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.New(NewContextHandler(slog.Default().Handler()).withContext(ctx))
+}
@@ -0,0 +1,58 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestContextHandler(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(NewContextHandler(slog.NewJSONHandler(&buf, nil)))
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	ctx = ContextWithAttrs(ctx, slog.String("method", "/helloworld.Greeter/SayHello"))
+	ctx = ContextWithAttrs(ctx, slog.String("peer", "127.0.0.1:1234"))
+
+	decode := func() map[string]any {
+		m := map[string]any{}
+		assert.Nil(t, json.Unmarshal(buf.Bytes(), &m))
+		buf.Reset()
+		return m
+	}
+
+	l.InfoContext(ctx, "hello")
+	m := decode()
+	assert.Equal(t, sc.TraceID().String(), m[TraceIDKey])
+	assert.Equal(t, sc.SpanID().String(), m[SpanIDKey])
+	assert.Equal(t, "/helloworld.Greeter/SayHello", m["method"])
+	assert.Equal(t, "127.0.0.1:1234", m["peer"])
+
+	l.Info("hello")
+	m = decode()
+	assert.NotContains(t, m, TraceIDKey)
+	assert.NotContains(t, m, "method")
+
+	// the logger of FromContext is bound to the request context
+	old := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(old) })
+	slog.SetDefault(l)
+	FromContext(ctx).Info("hello")
+	m = decode()
+	assert.Equal(t, sc.TraceID().String(), m[TraceIDKey])
+	assert.Equal(t, "127.0.0.1:1234", m["peer"])
+
+	injected := l.With("k", "v")
+	FromContext(NewContext(ctx, injected)).Info("hello")
+	m = decode()
+	assert.Equal(t, "v", m["k"])
+}
@@ -0,0 +1,227 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Format is the format of an output.
+type Format int
+
+const (
+	// JSON is the slog.JSONHandler format
+	JSON Format = iota
+	// Text is the slog.TextHandler format
+	Text
+	// Console is a colored human-readable format for local development
+	Console
+)
+
+// newFormatHandler returns the handler writing the records to w in format.
+func newFormatHandler(w io.Writer, format Format, opts *slog.HandlerOptions) slog.Handler {
+	switch format {
+	case Text:
+		return slog.NewTextHandler(w, opts)
+	case Console:
+		return newConsoleHandler(w, opts)
+	default:
+		return slog.NewJSONHandler(w, opts)
+	}
+}
+
+// fanoutHandler sends every record to all the handlers enabled for its level.
+type fanoutHandler []slog.Handler
+
+// NewFanoutHandler returns a handler sending every record to hs.
+func NewFanoutHandler(hs ...slog.Handler) slog.Handler {
+	if len(hs) == 1 {
+		return hs[0]
+	}
+	return fanoutHandler(hs)
+}
+
+func (f fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			err = errors.CombineErrors(err, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return err
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	hs := make(fanoutHandler, len(f))
+	for i, h := range f {
+		hs[i] = h.WithAttrs(attrs)
+	}
+	return hs
+}
+
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	hs := make(fanoutHandler, len(f))
+	for i, h := range f {
+		hs[i] = h.WithGroup(name)
+	}
+	return hs
+}
+
+const (
+	colorReset  = "\033[0m"
+	colorGray   = "\033[90m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorCyan   = "\033[36m"
+)
+
+// consoleHandler writes `15:04:05.000 INF msg k=v` lines with the level colored.
+type consoleHandler struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	opts   slog.HandlerOptions
+	attrs  string // preformatted attributes of WithAttrs
+	groups []string
+}
+
+func newConsoleHandler(w io.Writer, opts *slog.HandlerOptions) *consoleHandler {
+	h := &consoleHandler{mu: &sync.Mutex{}, w: w}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	min := slog.LevelInfo
+	if h.opts.Level != nil {
+		min = h.opts.Level.Level()
+	}
+	return level >= min
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	var buf bytes.Buffer
+	if !r.Time.IsZero() {
+		buf.WriteString(colorGray + r.Time.Format("15:04:05.000") + colorReset + " ")
+	}
+	buf.WriteString(levelColor(r.Level) + levelName(r.Level) + colorReset + " ")
+	buf.WriteString(r.Message)
+	buf.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		h.appendAttr(&buf, h.groups, a)
+		return true
+	})
+	buf.WriteByte('\n')
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf.Bytes())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var buf bytes.Buffer
+	for _, a := range attrs {
+		h.appendAttr(&buf, h.groups, a)
+	}
+	h2 := *h
+	h2.attrs += buf.String()
+	return &h2
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	return &h2
+}
+
+func (h *consoleHandler) appendAttr(buf *bytes.Buffer, groups []string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if h.opts.ReplaceAttr != nil && a.Value.Kind() != slog.KindGroup {
+		a = h.opts.ReplaceAttr(groups, a)
+		a.Value = a.Value.Resolve()
+	}
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			groups = append(groups[:len(groups):len(groups)], a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			h.appendAttr(buf, groups, ga)
+		}
+		return
+	}
+	key := a.Key
+	if len(groups) > 0 {
+		key = strings.Join(groups, ".") + "." + key
+	}
+	buf.WriteString(" " + colorCyan + key + "=" + colorReset)
+	buf.WriteString(consoleValue(a.Value))
+}
+
+func consoleValue(v slog.Value) string {
+	var s string
+	switch v.Kind() {
+	case slog.KindString:
+		s = v.String()
+	case slog.KindTime:
+		s = v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		s = fmt.Sprintf("%+v", v.Any())
+	default:
+		s = v.String()
+	}
+	if s == "" || strings.ContainsAny(s, " \"=\n\t") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func levelName(l slog.Level) string {
+	switch {
+	case l >= slog.LevelError:
+		return "ERR"
+	case l >= slog.LevelWarn:
+		return "WRN"
+	case l >= slog.LevelInfo:
+		return "INF"
+	default:
+		return "DBG"
+	}
+}
+
+func levelColor(l slog.Level) string {
+	switch {
+	case l >= slog.LevelError:
+		return colorRed
+	case l >= slog.LevelWarn:
+		return colorYellow
+	case l >= slog.LevelInfo:
+		return colorGreen
+	default:
+		return colorGray
+	}
+}
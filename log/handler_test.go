@@ -0,0 +1,37 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/mdobak/go-xerrors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputs(t *testing.T) {
+	var jsonBuf, consoleBuf bytes.Buffer
+	Init(WithLevel(slog.LevelInfo),
+		WithOutput(&jsonBuf, JSON),
+		WithOutput(&consoleBuf, Console),
+		WithAttrs(slog.String("service", "greeter"), slog.String("version", "v1.0.0")))
+	defer Init()
+
+	slog.Debug("dropped")
+	slog.Info("hello", "name", "world")
+	slog.Error("failed", "err", xerrors.New("boom"))
+
+	lines := bytes.Split(bytes.TrimSpace(jsonBuf.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 2)
+	m := map[string]any{}
+	assert.Nil(t, json.Unmarshal(lines[0], &m))
+	assert.Equal(t, "greeter", m["service"])
+	assert.Equal(t, "world", m["name"])
+
+	console := consoleBuf.String()
+	assert.NotContains(t, console, "dropped")
+	assert.Contains(t, console, colorGreen+"INF"+colorReset+" hello")
+	assert.Contains(t, console, "version="+colorReset+"v1.0.0")
+	assert.Contains(t, console, "err.msg="+colorReset+"boom")
+}
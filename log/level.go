@@ -0,0 +1,174 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// MethodKey is the attribute key of the rpc method, the method level overrides
+// apply to the records logged with it in the context.
+const MethodKey = "method"
+
+// levels holds the level of the default logger and the overrides by method and by package.
+var levels = newLevelTable()
+
+// levelTable is a slog.Leveler returning the lowest level of the table, the
+// handlers let the ContextHandler filter the records with the overrides.
+type levelTable struct {
+	base     slog.LevelVar
+	floor    slog.LevelVar
+	mu       sync.RWMutex
+	methods  map[string]slog.Level
+	packages map[string]slog.Level
+}
+
+func newLevelTable() *levelTable {
+	return &levelTable{methods: map[string]slog.Level{}, packages: map[string]slog.Level{}}
+}
+
+func (t *levelTable) Level() slog.Level {
+	return t.floor.Level()
+}
+
+// update recomputes the floor, t.mu is held.
+func (t *levelTable) update() {
+	floor := t.base.Level()
+	for _, l := range t.methods {
+		floor = min(floor, l)
+	}
+	for _, l := range t.packages {
+		floor = min(floor, l)
+	}
+	t.floor.Set(floor)
+}
+
+func (t *levelTable) setBase(l slog.Level) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.base.Set(l)
+	t.update()
+}
+
+// enabled reports whether the record of level logged from pc with ctx passes the overrides,
+// the method override is checked first, then the most specific package override.
+func (t *levelTable) enabled(ctx context.Context, level slog.Level, pc uintptr) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(t.methods) == 0 && len(t.packages) == 0 {
+		return level >= t.base.Level()
+	}
+	if len(t.methods) > 0 && ctx != nil {
+		if l, ok := t.methods[methodFromContext(ctx)]; ok {
+			return level >= l
+		}
+	}
+	if len(t.packages) > 0 && pc != 0 {
+		pkg := packageOf(pc)
+		best, found := "", false
+		for p := range t.packages {
+			if (pkg == p || strings.HasPrefix(pkg, p+"/")) && len(p) >= len(best) {
+				best, found = p, true
+			}
+		}
+		if found {
+			return level >= t.packages[best]
+		}
+	}
+	return level >= t.base.Level()
+}
+
+func methodFromContext(ctx context.Context) string {
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	for i := len(attrs) - 1; i >= 0; i-- {
+		if attrs[i].Key == MethodKey {
+			return attrs[i].Value.String()
+		}
+	}
+	return ""
+}
+
+// packageOf returns the import path of the function of pc, e.g.
+// github.com/a/b/c of github.com/a/b/c.(*T).M.
+func packageOf(pc uintptr) string {
+	fs := runtime.CallersFrames([]uintptr{pc})
+	f, _ := fs.Next()
+	return funcPackage(f.Function)
+}
+
+// levelsSnapshot is the state of the table reported by the admin endpoints.
+type levelsSnapshot struct {
+	Level    string            `json:"level"`
+	Methods  map[string]string `json:"methods,omitempty"`
+	Packages map[string]string `json:"packages,omitempty"`
+}
+
+func (t *levelTable) snapshot() levelsSnapshot {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	s := levelsSnapshot{Level: t.base.Level().String(), Methods: map[string]string{}, Packages: map[string]string{}}
+	for m, l := range t.methods {
+		s.Methods[m] = l.String()
+	}
+	for p, l := range t.packages {
+		s.Packages[p] = l.String()
+	}
+	return s
+}
+
+func (t *levelTable) setOverride(m map[string]slog.Level, key string, l slog.Level) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m[key] = l
+	t.update()
+}
+
+func (t *levelTable) deleteOverride(m map[string]slog.Level, key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(m, key)
+	t.update()
+}
+
+// Level returns the level of the default logger.
+func Level() slog.Level {
+	return levels.base.Level()
+}
+
+// SetLevel changes the level of the default logger at runtime.
+func SetLevel(l slog.Level) {
+	levels.setBase(l)
+}
+
+// SetMethodLevel overrides the level of the records logged while serving
+// method, e.g. /helloworld.Greeter/SayHello.
+func SetMethodLevel(method string, l slog.Level) {
+	levels.setOverride(levels.methods, method, l)
+}
+
+// DeleteMethodLevel removes the level override of method.
+func DeleteMethodLevel(method string) {
+	levels.deleteOverride(levels.methods, method)
+}
+
+// SetPackageLevel overrides the level of the records logged from the package
+// pkg and its sub-packages, e.g. github.com/shenjing023/vivy-polaris/server.
+func SetPackageLevel(pkg string, l slog.Level) {
+	levels.setOverride(levels.packages, pkg, l)
+}
+
+// DeletePackageLevel removes the level override of pkg.
+func DeletePackageLevel(pkg string) {
+	levels.deleteOverride(levels.packages, pkg)
+}
+
+// ResetLevels removes every override.
+func ResetLevels() {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	clear(levels.methods)
+	clear(levels.packages)
+	levels.update()
+}
@@ -0,0 +1,93 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestLevels(t *testing.T) {
+	var buf bytes.Buffer
+	Init(WithLevel(slog.LevelInfo), WithOutput(&buf, Text))
+	defer Init()
+	defer ResetLevels()
+
+	method := "/helloworld.Greeter/SayHello"
+	ctx := ContextWithAttrs(context.Background(), slog.String(MethodKey, method))
+	logged := func(f func()) bool {
+		buf.Reset()
+		f()
+		return buf.Len() > 0
+	}
+
+	assert.False(t, logged(func() { slog.DebugContext(ctx, "debug") }))
+
+	SetMethodLevel(method, slog.LevelDebug)
+	assert.True(t, logged(func() { slog.DebugContext(ctx, "debug") }))
+	assert.True(t, logged(func() { FromContext(ctx).Debug("debug") }))
+	assert.False(t, logged(func() { slog.Debug("debug") }))
+	DeleteMethodLevel(method)
+	assert.False(t, logged(func() { slog.DebugContext(ctx, "debug") }))
+
+	// the most specific package wins
+	SetPackageLevel("github.com/shenjing023", slog.LevelDebug)
+	SetPackageLevel("github.com/shenjing023/vivy-polaris/log", slog.LevelError)
+	assert.False(t, logged(func() { slog.Warn("warn") }))
+	DeletePackageLevel("github.com/shenjing023/vivy-polaris/log")
+	assert.True(t, logged(func() { slog.Debug("debug") }))
+	ResetLevels()
+
+	SetLevel(slog.LevelWarn)
+	assert.False(t, logged(func() { slog.Info("info") }))
+	assert.Equal(t, slog.LevelWarn, Level())
+}
+
+func TestLevelHandler(t *testing.T) {
+	Init()
+	defer ResetLevels()
+	h := LevelHandler()
+
+	do := func(method, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/log/level", strings.NewReader(body)))
+		return w
+	}
+	w := do(http.MethodPut, `{"level":"debug","method":"/helloworld.Greeter/SayHello"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level":"INFO","methods":{"/helloworld.Greeter/SayHello":"DEBUG"}}`, w.Body.String())
+
+	w = do(http.MethodPut, `{"level":"verbose"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do(http.MethodDelete, "")
+	assert.JSONEq(t, `{"level":"INFO"}`, w.Body.String())
+
+	// the grpc service applies the same requests
+	in, err := structpb.NewStruct(map[string]any{"level": "warn"})
+	assert.Nil(t, err)
+	_, err = levelService{}.SetLevel(context.Background(), in)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	svc := levelService{auth: AllowPeers(netip.MustParsePrefix("127.0.0.0/8"))}
+	remote := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}})
+	_, err = svc.SetLevel(remote, in)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	local := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1234}})
+	out, err := svc.SetLevel(local, in)
+	assert.Nil(t, err)
+	assert.Equal(t, "WARN", out.GetFields()["level"].GetStringValue())
+	SetLevel(slog.LevelInfo)
+}
@@ -6,17 +6,35 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
 
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/errors/errbase"
 	"github.com/mdobak/go-xerrors"
 	"github.com/shenjing023/vivy-polaris/options"
 )
 
-var (
-	defaultOption = loggerOptions{
+// StackMode sets how the function and the source of the stack frames are logged.
+type StackMode int
+
+const (
+	// ShortPaths logs pkg.Func and dir/file.go, it is the default
+	ShortPaths StackMode = iota
+	// FullPaths logs github.com/a/b/pkg.Func and the absolute path of the file
+	FullPaths
+	// ModulePaths logs github.com/a/b/pkg.Func and github.com/a/b/pkg/file.go,
+	// independent of the build machine
+	ModulePaths
+)
+
+func newLoggerOptions() loggerOptions {
+	return loggerOptions{
 		maxFrameDepth: 5,
 		level:         slog.LevelInfo,
 	}
-)
+}
 
 type stackFrame struct {
 	Func   string `json:"func"`
@@ -24,49 +42,86 @@ type stackFrame struct {
 	Line   int    `json:"line"`
 }
 
-func replaceAttr(_ []string, a slog.Attr) slog.Attr {
+func (o *loggerOptions) replaceAttr(_ []string, a slog.Attr) slog.Attr {
 	switch a.Value.Kind() {
 	case slog.KindAny:
 		switch v := a.Value.Any().(type) {
 		case error:
-			a.Value = fmtErr(v)
+			a.Value = o.fmtErr(v)
 		}
 	}
 	return a
 }
 
+// callers returns the program counters of the stack of err, recorded by
+// go-xerrors or by cockroachdb/errors.
+func callers(err error) []uintptr {
+	if trace := xerrors.StackTrace(err); len(trace) > 0 {
+		return trace
+	}
+	// the innermost stack is the closest to the origin of the error
+	var trace errbase.StackTrace
+	for e := err; e != nil; e = errors.UnwrapOnce(e) {
+		if p, ok := e.(errbase.StackTraceProvider); ok {
+			trace = p.StackTrace()
+		}
+	}
+	pcs := make([]uintptr, len(trace))
+	for i, f := range trace {
+		pcs[i] = uintptr(f)
+	}
+	return pcs
+}
+
 // marshalStack extracts stack frames from the error
-func marshalStack(err error) []stackFrame {
-	trace := xerrors.StackTrace(err)
-	if len(trace) == 0 {
+func (o *loggerOptions) marshalStack(err error) []stackFrame {
+	pcs := callers(err)
+	if len(pcs) == 0 {
 		return nil
 	}
-	frames := trace.Frames()
-	if len(frames) > defaultOption.maxFrameDepth {
-		frames = frames[:defaultOption.maxFrameDepth]
-	}
-	s := make([]stackFrame, len(frames))
-	for i, v := range frames {
-		f := stackFrame{
-			Source: filepath.Join(
-				filepath.Base(filepath.Dir(v.File)),
-				filepath.Base(v.File),
-			),
-			Func: filepath.Base(v.Function),
-			Line: v.Line,
+	var s []stackFrame
+	frames := runtime.CallersFrames(pcs)
+	for len(s) < o.maxFrameDepth {
+		v, more := frames.Next()
+		if v.Function != "" {
+			s = append(s, o.stackFrame(v))
+		}
+		if !more {
+			break
 		}
-		s[i] = f
 	}
 	return s
 }
 
+func (o *loggerOptions) stackFrame(v runtime.Frame) stackFrame {
+	f := stackFrame{Func: v.Function, Source: v.File, Line: v.Line}
+	switch o.stackMode {
+	case FullPaths:
+	case ModulePaths:
+		f.Source = funcPackage(v.Function) + "/" + filepath.Base(v.File)
+	default:
+		f.Source = filepath.Join(filepath.Base(filepath.Dir(v.File)), filepath.Base(v.File))
+		f.Func = filepath.Base(v.Function)
+	}
+	return f
+}
+
+// funcPackage returns the import path of the function name, e.g.
+// github.com/a/b/c of github.com/a/b/c.(*T).M.
+func funcPackage(name string) string {
+	slash := strings.LastIndexByte(name, '/')
+	if dot := strings.IndexByte(name[slash+1:], '.'); dot >= 0 {
+		return name[:slash+1+dot]
+	}
+	return name
+}
+
 // fmtErr returns a slog.Value with keys `msg` and `trace`. If the error
-// does not implement interface { StackTrace() errors.StackTrace }, the `trace`
-// key is omitted.
-func fmtErr(err error) slog.Value {
+// carries no go-xerrors or cockroachdb/errors stack, the `trace` key is omitted.
+func (o *loggerOptions) fmtErr(err error) slog.Value {
 	var groupValues []slog.Attr
 	groupValues = append(groupValues, slog.String("msg", err.Error()))
-	frames := marshalStack(err)
+	frames := o.marshalStack(err)
 	if frames != nil {
 		groupValues = append(groupValues,
 			slog.Any("trace", frames),
@@ -75,14 +130,67 @@ func fmtErr(err error) slog.Value {
 	return slog.GroupValue(groupValues...)
 }
 
+// initOptions are the options of the last Init, used by ErrAttr
+var initOptions atomic.Pointer[loggerOptions]
+
+// ErrAttr returns the err attribute with the stack trace if the error carries
+// one, formatted with the frame depth and the stack mode of Init, the default
+// ones before Init.
+func ErrAttr(err error) slog.Attr {
+	o := initOptions.Load()
+	if o == nil {
+		d := newLoggerOptions()
+		o = &d
+	}
+	return slog.Attr{Key: "err", Value: o.fmtErr(err)}
+}
+
+// New returns a logger writing to the outputs of opts, it holds its own options
+// and level, which is not changed by SetLevel.
+func New(opts ...options.Option[loggerOptions]) *slog.Logger {
+	o := newLoggerOptions()
+	for _, opt := range opts {
+		opt.Apply(&o)
+	}
+	lt := newLevelTable()
+	lt.setBase(o.level)
+	return slog.New(newHandler(&o, lt))
+}
+
+// Init sets the default logger, writing to the outputs of opts.
+// The level can be changed at runtime with SetLevel and overridden by
+// method or by package, see LevelHandler, RegisterLevelService and WatchSignals.
 func Init(opts ...options.Option[loggerOptions]) {
+	o := newLoggerOptions()
 	for _, opt := range opts {
-		opt.Apply(&defaultOption)
+		opt.Apply(&o)
 	}
+	levels.setBase(o.level)
+	initOptions.Store(&o)
+	slog.SetDefault(slog.New(newHandler(&o, levels)))
+}
 
-	h := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		ReplaceAttr: replaceAttr,
-		Level:       defaultOption.level,
-	})
-	slog.SetDefault(slog.New(h))
+// newHandler returns the handler sending the records to every output of o,
+// filtered by lt.
+func newHandler(o *loggerOptions, lt *levelTable) slog.Handler {
+	hopts := &slog.HandlerOptions{
+		ReplaceAttr: o.replaceAttr,
+		Level:       lt,
+	}
+	outputs := o.outputs
+	if len(outputs) == 0 && len(o.handlers) == 0 {
+		outputs = []output{{w: os.Stdout, format: JSON}}
+	}
+	hs := make([]slog.Handler, 0, len(outputs)+len(o.handlers))
+	for _, out := range outputs {
+		hs = append(hs, newFormatHandler(out.w, out.format, hopts))
+	}
+	hs = append(hs, o.handlers...)
+	h := NewFanoutHandler(hs...)
+	if len(o.attrs) > 0 {
+		h = h.WithAttrs(o.attrs)
+	}
+	ch := *NewContextHandler(h)
+	ch.levels = lt
+	return &ch
 }
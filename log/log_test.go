@@ -1,10 +1,18 @@
 package log
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
 	"log/slog"
+	"path/filepath"
 	"testing"
 
+	"github.com/cockroachdb/errors"
 	"github.com/mdobak/go-xerrors"
+	"github.com/shenjing023/vivy-polaris/options"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestError(t *testing.T) {
@@ -44,3 +52,70 @@ func TestLogOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		opts   []options.Option[loggerOptions]
+		source string
+		fn     string
+		frames int
+	}{
+		{"xerrors short", xerrors.New("test error"), nil, "log/log_test.go", "log.TestNew", 5},
+		{"cockroachdb short", errors.New("test error"), []options.Option[loggerOptions]{WithMaxFrameDepth(1)},
+			"log/log_test.go", "log.TestNew", 1},
+		{"cockroachdb wrapped module", errors.Wrap(errors.New("test error"), "wrapped"),
+			[]options.Option[loggerOptions]{WithStackMode(ModulePaths)},
+			"github.com/shenjing023/vivy-polaris/log/log_test.go", "github.com/shenjing023/vivy-polaris/log.TestNew", 5},
+		{"full", xerrors.New("test error"), []options.Option[loggerOptions]{WithStackMode(FullPaths)},
+			"", "github.com/shenjing023/vivy-polaris/log.TestNew", 5},
+		{"no stack", fmt.Errorf("test error"), nil, "", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := New(append(tt.opts, WithOutput(&buf, JSON))...)
+			l.Error("failed", "err", tt.err)
+
+			var rec struct {
+				Err struct {
+					Msg   string       `json:"msg"`
+					Trace []stackFrame `json:"trace"`
+				} `json:"err"`
+			}
+			assert.Nil(t, json.Unmarshal(buf.Bytes(), &rec))
+			assert.Equal(t, tt.err.Error(), rec.Err.Msg)
+			if tt.frames == 0 {
+				assert.Empty(t, rec.Err.Trace)
+				return
+			}
+			assert.LessOrEqual(t, len(rec.Err.Trace), tt.frames)
+			assert.Equal(t, tt.fn, rec.Err.Trace[0].Func)
+			if tt.source != "" {
+				assert.Equal(t, tt.source, rec.Err.Trace[0].Source)
+			} else {
+				assert.True(t, filepath.IsAbs(rec.Err.Trace[0].Source))
+			}
+		})
+	}
+}
+
+func TestErrAttr(t *testing.T) {
+	old := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(old)
+		initOptions.Store(nil)
+	})
+	Init(WithOutput(io.Discard, JSON), WithMaxFrameDepth(1), WithStackMode(FullPaths))
+
+	attr := ErrAttr(xerrors.New("test error"))
+	var trace []stackFrame
+	for _, a := range attr.Value.Group() {
+		if a.Key == "trace" {
+			trace = a.Value.Any().([]stackFrame)
+		}
+	}
+	assert.Len(t, trace, 1)
+	assert.Equal(t, "github.com/shenjing023/vivy-polaris/log.TestErrAttr", trace[0].Func)
+}
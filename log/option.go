@@ -1,14 +1,24 @@
 package log
 
 import (
+	"io"
 	"log/slog"
 
 	"github.com/shenjing023/vivy-polaris/options"
 )
 
+type output struct {
+	w      io.Writer
+	format Format
+}
+
 type loggerOptions struct {
 	maxFrameDepth int
+	stackMode     StackMode
 	level         slog.Level
+	outputs       []output
+	handlers      []slog.Handler
+	attrs         []slog.Attr
 }
 
 // WithMaxFrameDepth sets the maximum frame depth for the logger.
@@ -18,9 +28,40 @@ func WithMaxFrameDepth(depth int) options.Option[loggerOptions] {
 	})
 }
 
+// WithStackMode sets how the stack frames of the errors are logged, default is ShortPaths.
+func WithStackMode(mode StackMode) options.Option[loggerOptions] {
+	return options.NewFuncOption(func(o *loggerOptions) {
+		o.stackMode = mode
+	})
+}
+
 // WithLevel sets the log level for the logger.
 func WithLevel(level slog.Level) options.Option[loggerOptions] {
 	return options.NewFuncOption(func(o *loggerOptions) {
 		o.level = level
 	})
 }
+
+// WithOutput adds an output writing the records to w in format, e.g. a
+// RotatingFile in JSON and os.Stderr in Console. Every record is sent to all
+// the outputs, default is os.Stdout in JSON.
+func WithOutput(w io.Writer, format Format) options.Option[loggerOptions] {
+	return options.NewFuncOption(func(o *loggerOptions) {
+		o.outputs = append(o.outputs, output{w: w, format: format})
+	})
+}
+
+// WithHandler adds h as an output, the level and the formatting of the
+// records are left to h.
+func WithHandler(h slog.Handler) options.Option[loggerOptions] {
+	return options.NewFuncOption(func(o *loggerOptions) {
+		o.handlers = append(o.handlers, h)
+	})
+}
+
+// WithAttrs adds static attributes to every record, e.g. the service name and version.
+func WithAttrs(attrs ...slog.Attr) options.Option[loggerOptions] {
+	return options.NewFuncOption(func(o *loggerOptions) {
+		o.attrs = append(o.attrs, attrs...)
+	})
+}
@@ -0,0 +1,244 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/shenjing023/vivy-polaris/options"
+)
+
+// backupTimeFormat is appended to the rotated file names
+const backupTimeFormat = "20060102-150405.000"
+
+type rotateOptions struct {
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+}
+
+// WithMaxSize rotates the file once it reaches size bytes, 0 disables it.
+// Default is 100MB.
+func WithMaxSize(size int64) options.Option[rotateOptions] {
+	return options.NewFuncOption(func(o *rotateOptions) {
+		o.maxSize = size
+	})
+}
+
+// WithRotateInterval rotates the file at every multiple of d, e.g. 24*time.Hour
+// rotates daily at midnight UTC, 0 disables it.
+func WithRotateInterval(d time.Duration) options.Option[rotateOptions] {
+	return options.NewFuncOption(func(o *rotateOptions) {
+		o.interval = d
+	})
+}
+
+// WithMaxBackups keeps the n most recent rotated files, 0 keeps them all.
+func WithMaxBackups(n int) options.Option[rotateOptions] {
+	return options.NewFuncOption(func(o *rotateOptions) {
+		o.maxBackups = n
+	})
+}
+
+// RotatingFile is an io.Writer appending to a file which is rotated by size
+// and by time. The rotated files are renamed to path.<time>, followed by
+// .<n> when the name is taken.
+type RotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	opts     rotateOptions
+	file     *os.File
+	size     int64
+	base     int64 // size of the file when it was last rotated or failed to
+	openedAt time.Time
+	now      func() time.Time
+	rename   func(oldpath, newpath string) error
+}
+
+// NewRotatingFile opens or creates the file at path.
+func NewRotatingFile(path string, opts ...options.Option[rotateOptions]) (*RotatingFile, error) {
+	f := &RotatingFile{
+		path:   path,
+		opts:   rotateOptions{maxSize: 100 << 20},
+		now:    time.Now,
+		rename: os.Rename,
+	}
+	for _, opt := range opts {
+		opt.Apply(&f.opts)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create the log dir of %s", path)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", f.path)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.Wrapf(err, "failed to stat %s", f.path)
+	}
+	f.file, f.size, f.base, f.openedAt = file, info.Size(), 0, info.ModTime()
+	if f.size == 0 {
+		f.openedAt = f.now()
+	}
+	return nil
+}
+
+// Write appends p to the file, rotating it first if needed. A failed rotation
+// is reported to stderr and p is appended to the file still open.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.shouldRotate(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			if f.file == nil {
+				return 0, err
+			}
+			fmt.Fprintf(os.Stderr, "log: %v\n", err)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) shouldRotate(n int64) bool {
+	if f.size == f.base {
+		return false
+	}
+	if f.opts.maxSize > 0 && f.size-f.base+n > f.opts.maxSize {
+		return true
+	}
+	return f.opts.interval > 0 && !f.now().Truncate(f.opts.interval).Equal(f.openedAt.Truncate(f.opts.interval))
+}
+
+// rotate renames the current file and opens a new one, the file is nil only
+// if none could be opened. After a failed rename, the current file is kept
+// and the next rotation is delayed by another size or interval.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return errors.Wrapf(err, "failed to close %s", f.path)
+	}
+	f.file = nil
+	now := f.now()
+	if err := f.rename(f.path, f.backupName(now)); err != nil {
+		err = errors.Wrapf(err, "failed to rename %s", f.path)
+		if oerr := f.open(); oerr != nil {
+			return errors.CombineErrors(err, oerr)
+		}
+		f.base, f.openedAt = f.size, now
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.removeBackups()
+}
+
+// backupName returns the first name of the backup rotated at t not taken yet.
+func (f *RotatingFile) backupName(t time.Time) string {
+	name := f.path + "." + t.Format(backupTimeFormat)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(name); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s.%s.%d", f.path, t.Format(backupTimeFormat), i)
+	}
+}
+
+type backup struct {
+	name string
+	at   time.Time
+	n    int
+}
+
+// parseBackup parses the name of a file of the dir of the log file, ok is
+// false if it is not a backup of the log file.
+func (f *RotatingFile) parseBackup(name string) (b backup, ok bool) {
+	rest, ok := strings.CutPrefix(name, filepath.Base(f.path)+".")
+	if !ok || len(rest) < len(backupTimeFormat) {
+		return b, false
+	}
+	at, err := time.Parse(backupTimeFormat, rest[:len(backupTimeFormat)])
+	if err != nil {
+		return b, false
+	}
+	b = backup{name: filepath.Join(filepath.Dir(f.path), name), at: at}
+	if rest = rest[len(backupTimeFormat):]; rest != "" {
+		n, err := strconv.Atoi(strings.TrimPrefix(rest, "."))
+		if rest[0] != '.' || err != nil || n <= 0 {
+			return b, false
+		}
+		b.n = n
+	}
+	return b, true
+}
+
+// removeBackups removes the oldest rotated files beyond maxBackups.
+func (f *RotatingFile) removeBackups() error {
+	if f.opts.maxBackups <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(filepath.Dir(f.path))
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the backups of %s", f.path)
+	}
+	var backups []backup
+	for _, e := range entries {
+		if b, ok := f.parseBackup(e.Name()); ok {
+			backups = append(backups, b)
+		}
+	}
+	if len(backups) <= f.opts.maxBackups {
+		return nil
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].at.Equal(backups[j].at) {
+			return backups[i].at.Before(backups[j].at)
+		}
+		return backups[i].n < backups[j].n
+	})
+	var errs error
+	for _, b := range backups[:len(backups)-f.opts.maxBackups] {
+		errs = errors.CombineErrors(errs, os.Remove(b.name))
+	}
+	return errs
+}
+
+// Rotate rotates the file now, e.g. on SIGHUP.
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
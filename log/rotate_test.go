@@ -0,0 +1,120 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	f, err := NewRotatingFile(path, WithMaxSize(10), WithRotateInterval(24*time.Hour), WithMaxBackups(2))
+	assert.Nil(t, err)
+	defer f.Close()
+	f.now = func() time.Time { return now }
+	f.openedAt = now
+
+	count := func() int {
+		backups, err := filepath.Glob(path + ".*")
+		assert.Nil(t, err)
+		return len(backups)
+	}
+
+	_, err = f.Write([]byte("12345678\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count())
+
+	// rotated by size
+	now = now.Add(time.Second)
+	_, err = f.Write([]byte("12345678\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count())
+
+	// rotated by time
+	now = now.Add(14 * time.Hour)
+	_, err = f.Write([]byte("1\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count())
+
+	// the oldest backup is removed
+	now = now.Add(time.Second)
+	assert.Nil(t, f.Rotate())
+	assert.Equal(t, 2, count())
+
+	b, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Empty(t, b)
+}
+
+func TestRotatingFileBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	f, err := NewRotatingFile(path, WithMaxSize(0), WithMaxBackups(2))
+	assert.Nil(t, err)
+	defer f.Close()
+	f.now = func() time.Time { return now }
+
+	// the unrelated files are kept
+	for _, name := range []string{"app.log.gz", "app.log.lock", "app.log.20240101-090000.000.x"} {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), nil, 0o644))
+	}
+	// the rotations of the same millisecond do not overwrite each other
+	for _, line := range []string{"1\n", "2\n", "3\n"} {
+		_, err = f.Write([]byte(line))
+		assert.Nil(t, err)
+		assert.Nil(t, f.Rotate())
+	}
+	names := func() (names []string) {
+		entries, err := os.ReadDir(dir)
+		assert.Nil(t, err)
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		return names
+	}
+	assert.ElementsMatch(t, []string{
+		"app.log", "app.log.gz", "app.log.lock", "app.log.20240101-090000.000.x",
+		"app.log.20240101-100000.000.1", "app.log.20240101-100000.000.2",
+	}, names())
+	b, err := os.ReadFile(path + ".20240101-100000.000.2")
+	assert.Nil(t, err)
+	assert.Equal(t, "3\n", string(b))
+}
+
+func TestRotatingFileFailures(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := NewRotatingFile(path, WithMaxSize(10), WithMaxBackups(1))
+	assert.Nil(t, err)
+	defer f.Close()
+
+	// the lines are kept in the current file when the rename fails, and the
+	// rotation is retried once the file grew by another max size
+	renames := 0
+	f.rename = func(oldpath, newpath string) error {
+		renames++
+		return os.ErrPermission
+	}
+	for i := 0; i < 3; i++ {
+		_, err = f.Write([]byte("12345678\n"))
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 2, renames)
+	b, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Len(t, b, 27)
+
+	// the line is written when the old backups cannot be removed
+	f.rename = os.Rename
+	assert.Nil(t, os.MkdirAll(filepath.Join(path+".20000101-000000.000", "x"), 0o755))
+	_, err = f.Write([]byte("12345678\n"))
+	assert.Nil(t, err)
+	b, err = os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "12345678\n", string(b))
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package log
+
+// WatchSignals does nothing, SIGUSR1 and SIGUSR2 are not available.
+func WatchSignals() (stop func()) {
+	return func() {}
+}
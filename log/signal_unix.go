@@ -0,0 +1,42 @@
+//go:build unix
+
+package log
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WatchSignals sets the level to debug on SIGUSR1 and restores the previous
+// level on SIGUSR2 until stop is called.
+func WatchSignals() (stop func()) {
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		prev := Level()
+		for {
+			select {
+			case sig := <-c:
+				switch sig {
+				case syscall.SIGUSR1:
+					if Level() != slog.LevelDebug {
+						prev = Level()
+					}
+					SetLevel(slog.LevelDebug)
+				case syscall.SIGUSR2:
+					SetLevel(prev)
+				}
+				slog.Info("log level changed", "signal", sig.String(), "level", Level().String())
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(c)
+		close(done)
+	}
+}
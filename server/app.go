@@ -0,0 +1,299 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/shenjing023/vivy-polaris/contrib/metrics/prometheus"
+	"github.com/shenjing023/vivy-polaris/log"
+	"github.com/shenjing023/vivy-polaris/options"
+	"google.golang.org/grpc"
+)
+
+// Hook is called during the app lifecycle, e.g. etcd register/deregister
+// or repository init/close.
+type Hook func(ctx context.Context) error
+
+// App owns the listener, the signal handling and the lifecycle hooks of a grpc server.
+//
+// The lifecycle is:
+//
+//	listen -> beforeStart hooks -> serve -> afterStart hooks -> wait for signal or Stop
+//	-> health NOT_SERVING -> beforeStop hooks -> GracefulStop (Stop after stopTimeout) -> afterStop hooks
+type App struct {
+	srv  *grpc.Server
+	addr string
+	opts appOptions
+
+	stopOnce   sync.Once
+	quit       chan struct{}
+	metricsSrv *http.Server
+}
+
+type appOptions struct {
+	lis         net.Listener
+	signals     []os.Signal
+	stopTimeout time.Duration
+	beforeStart []Hook
+	afterStart  []Hook
+	beforeStop  []Hook
+	afterStop   []Hook
+	metricsAddr string
+	metrics     http.Handler
+	logLevel    bool
+	logSignals  bool
+}
+
+// NewApp returns an App serving srv on addr, addr is ignored when WithListener is used.
+func NewApp(srv *grpc.Server, addr string, opts ...options.Option[appOptions]) *App {
+	a := &App{
+		srv:  srv,
+		addr: addr,
+		opts: appOptions{
+			signals:     []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT},
+			stopTimeout: 10 * time.Second,
+		},
+		quit: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt.Apply(&a.opts)
+	}
+	return a
+}
+
+// WithListener serves on lis instead of listening on addr.
+func WithListener(lis net.Listener) options.Option[appOptions] {
+	return options.NewFuncOption(func(o *appOptions) {
+		o.lis = lis
+	})
+}
+
+// WithSignals sets the signals that trigger a graceful stop,
+// default are SIGINT, SIGTERM and SIGQUIT.
+func WithSignals(sigs ...os.Signal) options.Option[appOptions] {
+	return options.NewFuncOption(func(o *appOptions) {
+		o.signals = sigs
+	})
+}
+
+// WithStopTimeout sets the graceful stop deadline, the server is stopped
+// forcibly when GracefulStop does not return in time. Default is 10s.
+func WithStopTimeout(d time.Duration) options.Option[appOptions] {
+	return options.NewFuncOption(func(o *appOptions) {
+		o.stopTimeout = d
+	})
+}
+
+// WithBeforeStart adds hooks running in order before the server starts serving.
+func WithBeforeStart(hooks ...Hook) options.Option[appOptions] {
+	return options.NewFuncOption(func(o *appOptions) {
+		o.beforeStart = append(o.beforeStart, hooks...)
+	})
+}
+
+// WithAfterStart adds hooks running in order after the server starts serving,
+// e.g. register the service to etcd.
+func WithAfterStart(hooks ...Hook) options.Option[appOptions] {
+	return options.NewFuncOption(func(o *appOptions) {
+		o.afterStart = append(o.afterStart, hooks...)
+	})
+}
+
+// WithBeforeStop adds hooks running in order before the server stops,
+// e.g. deregister the service from etcd.
+func WithBeforeStop(hooks ...Hook) options.Option[appOptions] {
+	return options.NewFuncOption(func(o *appOptions) {
+		o.beforeStop = append(o.beforeStop, hooks...)
+	})
+}
+
+// WithAfterStop adds hooks running in order after the server stopped,
+// e.g. close the repository connections.
+func WithAfterStop(hooks ...Hook) options.Option[appOptions] {
+	return options.NewFuncOption(func(o *appOptions) {
+		o.afterStop = append(o.afterStop, hooks...)
+	})
+}
+
+// WithMetricsEndpoint serves the prometheus metrics on http://addr/metrics
+// while the app is running, the ones of prometheus.DefaultGatherer unless
+// WithMetricsHandler is used.
+func WithMetricsEndpoint(addr string) options.Option[appOptions] {
+	return options.NewFuncOption(func(o *appOptions) {
+		o.metricsAddr = addr
+	})
+}
+
+// WithMetricsHandler sets the handler of the metrics endpoint, e.g. the Handler
+// of the ServerMetrics of WithServerMetrics registered with a custom registerer.
+func WithMetricsHandler(h http.Handler) options.Option[appOptions] {
+	return options.NewFuncOption(func(o *appOptions) {
+		o.metrics = h
+	})
+}
+
+// WithLogLevelEndpoint serves the admin handler of the log levels on
+// http://addr/log/level, addr being the one of WithMetricsEndpoint which is
+// required, Run fails without it.
+func WithLogLevelEndpoint() options.Option[appOptions] {
+	return options.NewFuncOption(func(o *appOptions) {
+		o.logLevel = true
+	})
+}
+
+// WithLogSignals sets the log level to debug on SIGUSR1 and restores it on SIGUSR2
+// while the app is running.
+func WithLogSignals() options.Option[appOptions] {
+	return options.NewFuncOption(func(o *appOptions) {
+		o.logSignals = true
+	})
+}
+
+// Server returns the wrapped grpc server.
+func (a *App) Server() *grpc.Server {
+	return a.srv
+}
+
+// Run starts the server and blocks until a signal is received, Stop is called
+// or the server fails.
+func (a *App) Run() error {
+	if a.opts.logLevel && a.opts.metricsAddr == "" {
+		return errors.New("WithLogLevelEndpoint requires WithMetricsEndpoint")
+	}
+	lis := a.opts.lis
+	if lis == nil {
+		var err error
+		lis, err = net.Listen("tcp", a.addr)
+		if err != nil {
+			return errors.Wrapf(err, "failed to listen on %s", a.addr)
+		}
+	}
+	// the signals received while starting stop the app once it started
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, a.opts.signals...)
+	defer signal.Stop(quit)
+
+	ctx := context.Background()
+	if err := runHooks(ctx, a.opts.beforeStart); err != nil {
+		lis.Close()
+		return err
+	}
+	if a.opts.metricsAddr != "" {
+		if err := a.serveMetrics(); err != nil {
+			lis.Close()
+			return errors.CombineErrors(err, a.shutdown(ctx))
+		}
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- a.srv.Serve(lis)
+	}()
+	slog.Info("server listening", "addr", lis.Addr().String())
+
+	if err := runHooks(ctx, a.opts.afterStart); err != nil {
+		return errors.CombineErrors(err, a.shutdown(ctx))
+	}
+
+	if a.opts.logSignals {
+		defer log.WatchSignals()()
+	}
+
+	select {
+	case err := <-serveErr:
+		// the server stopped by itself, still release the resources
+		return errors.CombineErrors(errors.Wrap(err, "failed to serve"), a.shutdown(ctx))
+	case sig := <-quit:
+		slog.Info("signal received and shutdown server", "signal", sig.String())
+	case <-a.quit:
+		slog.Info("shutdown server")
+	}
+	return a.shutdown(ctx)
+}
+
+// Stop triggers the shutdown of a running App.
+func (a *App) Stop() {
+	a.stopOnce.Do(func() {
+		close(a.quit)
+	})
+}
+
+// shutdown runs every stop hook even if some of them fail.
+func (a *App) shutdown(ctx context.Context) error {
+	if h := GetHealth(a.srv); h != nil {
+		h.Shutdown()
+		defer healths.Delete(a.srv)
+	}
+	var err error
+	for _, h := range a.opts.beforeStop {
+		err = errors.CombineErrors(err, h(ctx))
+	}
+	a.gracefulStop()
+	if a.metricsSrv != nil {
+		err = errors.CombineErrors(err, a.metricsSrv.Shutdown(ctx))
+	}
+	for _, h := range a.opts.afterStop {
+		err = errors.CombineErrors(err, h(ctx))
+	}
+	return err
+}
+
+// gracefulStop falls back to Stop when GracefulStop exceeds the stop timeout.
+func (a *App) gracefulStop() {
+	done := make(chan struct{})
+	go func() {
+		a.srv.GracefulStop()
+		close(done)
+	}()
+	t := time.NewTimer(a.opts.stopTimeout)
+	defer t.Stop()
+	select {
+	case <-done:
+	case <-t.C:
+		slog.Warn("graceful stop timeout, force stop", "timeout", a.opts.stopTimeout)
+		a.srv.Stop()
+		<-done
+	}
+}
+
+func (a *App) serveMetrics() error {
+	lis, err := net.Listen("tcp", a.opts.metricsAddr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen on %s", a.opts.metricsAddr)
+	}
+	mux := http.NewServeMux()
+	if a.opts.metrics != nil {
+		mux.Handle("/metrics", a.opts.metrics)
+	} else {
+		mux.Handle("/metrics", prometheus.Handler())
+	}
+	if a.opts.logLevel {
+		mux.Handle("/log/level", log.LevelHandler())
+	}
+	a.metricsSrv = &http.Server{Handler: mux}
+	go func() {
+		if err := a.metricsSrv.Serve(lis); err != nil && err != http.ErrServerClosed {
+			slog.Error("failed to serve metrics", "err", err)
+		}
+	}()
+	slog.Info("metrics listening", "addr", lis.Addr().String())
+	return nil
+}
+
+// runHooks runs hooks in order and returns the first error.
+func runHooks(ctx context.Context, hooks []Hook) error {
+	for _, h := range hooks {
+		if err := h(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/shenjing023/vivy-polaris/contrib/metrics/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppLifecycle(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	var calls []string
+	hook := func(name string) Hook {
+		return func(ctx context.Context) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	app := NewApp(NewServer(), "", WithListener(lis), WithStopTimeout(time.Second),
+		WithBeforeStart(hook("beforeStart")),
+		WithAfterStart(hook("afterStart")),
+		WithBeforeStop(hook("beforeStop")),
+		WithAfterStop(hook("afterStop")),
+	)
+	done := make(chan error, 1)
+	go func() {
+		done <- app.Run()
+	}()
+	time.Sleep(100 * time.Millisecond)
+	app.Stop()
+
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("app did not stop")
+	}
+	assert.Equal(t, []string{"beforeStart", "afterStart", "beforeStop", "afterStop"}, calls)
+}
+
+func TestAppStopHooks(t *testing.T) {
+	var calls []string
+	hook := func(name string) Hook {
+		return func(ctx context.Context) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	// the stop hooks run when the metrics endpoint fails to start
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer busy.Close()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	app := NewApp(NewServer(), "", WithListener(lis), WithMetricsEndpoint(busy.Addr().String()),
+		WithBeforeStart(hook("beforeStart")),
+		WithAfterStop(hook("afterStop")),
+	)
+	assert.NotNil(t, app.Run())
+	assert.Equal(t, []string{"beforeStart", "afterStop"}, calls)
+
+	// a signal received while starting stops the app gracefully
+	calls = nil
+	lis, err = net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	app = NewApp(NewServer(), "", WithListener(lis), WithSignals(syscall.SIGHUP),
+		WithAfterStart(func(ctx context.Context) error {
+			p, err := os.FindProcess(os.Getpid())
+			if err != nil {
+				return err
+			}
+			return p.Signal(syscall.SIGHUP)
+		}),
+		WithAfterStop(hook("afterStop")),
+	)
+	done := make(chan error, 1)
+	go func() {
+		done <- app.Run()
+	}()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("app did not stop")
+	}
+	assert.Equal(t, []string{"afterStop"}, calls)
+}
+
+func TestAppMetricsEndpoint(t *testing.T) {
+	reg := prom.NewRegistry()
+	counter := prom.NewCounter(prom.CounterOpts{Name: "custom_total", Help: "custom counter"})
+	reg.MustRegister(counter)
+	counter.Inc()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := l.Addr().String()
+	l.Close()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	m := prometheus.NewServerMetrics(prometheus.WithRegisterer(reg))
+	srv := NewServer(WithServerMetrics(m))
+	app := NewApp(srv, "", WithListener(lis), WithMetricsEndpoint(addr), WithMetricsHandler(m.Handler()))
+	done := make(chan error, 1)
+	go func() {
+		done <- app.Run()
+	}()
+	defer func() {
+		app.Stop()
+		<-done
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	resp, err := http.Get("http://" + addr + "/metrics")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), "custom_total 1")
+}
+
+func TestAppLogLevelEndpointRequiresMetrics(t *testing.T) {
+	app := NewApp(NewServer(), "127.0.0.1:0", WithLogLevelEndpoint())
+	assert.NotNil(t, app.Run())
+}
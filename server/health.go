@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/shenjing023/vivy-polaris/contrib/registry"
+	"github.com/shenjing023/vivy-polaris/options"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// healths holds the Health of every server built with WithHealth
+var healths sync.Map
+
+var registryHooksOnce sync.Once
+
+// Health is the grpc_health_v1 service registered by WithHealth.
+// The status of every registered service is SERVING once the server
+// starts serving, it can be flipped with Shutdown and Resume.
+type Health struct {
+	*health.Server
+	srv  *grpc.Server
+	once sync.Once
+}
+
+// WithHealth registers the grpc_health_v1 service on the server,
+// the per-service status is driven by the registered ServiceDescs.
+func WithHealth() options.Option[serverOptions] {
+	return options.NewFuncOption(func(so *serverOptions) {
+		so.health = true
+	})
+}
+
+// GetHealth returns the Health of srv, nil if srv was not built with WithHealth.
+func GetHealth(srv *grpc.Server) *Health {
+	if h, ok := healths.Load(srv); ok {
+		return h.(*Health)
+	}
+	return nil
+}
+
+func registerHealth(srv *grpc.Server) {
+	h := &Health{
+		Server: health.NewServer(),
+		srv:    srv,
+	}
+	healthpb.RegisterHealthServer(srv, h)
+	healths.Store(srv, h)
+	registryHooksOnce.Do(func() {
+		registry.AddHooks(registry.Hooks{LeaseLost: leaseLost, Deregister: deregistered})
+	})
+}
+
+// serving calls f with the Health of every server serving service.
+func serving(service string, f func(srv *grpc.Server, h *Health)) {
+	healths.Range(func(k, v any) bool {
+		srv := k.(*grpc.Server)
+		if _, ok := srv.GetServiceInfo()[service]; ok {
+			f(srv, v.(*Health))
+		}
+		return true
+	})
+}
+
+// leaseLost stops the traffic to the servers of service once its etcd lease is lost.
+func leaseLost(service string) {
+	serving(service, func(_ *grpc.Server, h *Health) {
+		h.Shutdown()
+	})
+}
+
+// deregistered stops the traffic of service to its servers, the Health is kept
+// for the graceful stop of App.
+func deregistered(service string) {
+	serving(service, func(_ *grpc.Server, h *Health) {
+		h.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	})
+}
+
+// init sets every registered service to SERVING, the services must be
+// registered before serving so it is done lazily on the first call.
+func (h *Health) init() {
+	h.once.Do(func() {
+		for name := range h.srv.GetServiceInfo() {
+			if name == healthpb.Health_ServiceDesc.ServiceName {
+				continue
+			}
+			h.Server.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+		}
+	})
+}
+
+func (h *Health) Check(ctx context.Context, in *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	h.init()
+	return h.Server.Check(ctx, in)
+}
+
+func (h *Health) Watch(in *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	h.init()
+	return h.Server.Watch(in, stream)
+}
+
+// SetServingStatus sets the status of service, "" is the status of the whole server.
+func (h *Health) SetServingStatus(service string, status healthpb.HealthCheckResponse_ServingStatus) {
+	h.init()
+	h.Server.SetServingStatus(service, status)
+}
+
+// Shutdown sets every status to NOT_SERVING and ignores future changes until Resume,
+// it is called by App before the graceful stop and when the etcd lease of
+// one of the services registered by registry.NewEtcdRegister is lost.
+func (h *Health) Shutdown() {
+	h.init()
+	h.Server.Shutdown()
+}
+
+// Resume sets every status to SERVING and accepts future changes.
+func (h *Health) Resume() {
+	h.init()
+	h.Server.Resume()
+}
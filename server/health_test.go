@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shenjing023/vivy-polaris/example/pb"
+	"github.com/stretchr/testify/assert"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealth(t *testing.T) {
+	srv := NewServer(WithHealth())
+	pb.RegisterGreeterServer(srv, &pb.UnimplementedGreeterServer{})
+	h := GetHealth(srv)
+	assert.NotNil(t, h)
+
+	check := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := h.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		assert.Nil(t, err)
+		return resp.GetStatus()
+	}
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, check(""))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, check(pb.Greeter_ServiceDesc.ServiceName))
+
+	h.Shutdown()
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check(pb.Greeter_ServiceDesc.ServiceName))
+	h.Resume()
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, check(pb.Greeter_ServiceDesc.ServiceName))
+
+	assert.Nil(t, GetHealth(NewServer()))
+}
+
+func TestHealthLeaseLost(t *testing.T) {
+	srv := NewServer(WithHealth())
+	pb.RegisterGreeterServer(srv, &pb.UnimplementedGreeterServer{})
+	h := GetHealth(srv)
+	resp, err := h.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	leaseLost("unknown.Service")
+	resp, _ = h.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	leaseLost(pb.Greeter_ServiceDesc.ServiceName)
+	resp, _ = h.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.GetStatus())
+
+	// only the deregistered service stops serving, the Health is kept
+	h.Resume()
+	deregistered(pb.Greeter_ServiceDesc.ServiceName)
+	assert.Same(t, h, GetHealth(srv))
+	resp, _ = h.Check(context.Background(), &healthpb.HealthCheckRequest{Service: pb.Greeter_ServiceDesc.ServiceName})
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.GetStatus())
+	resp, _ = h.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+}
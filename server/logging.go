@@ -0,0 +1,32 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/shenjing023/vivy-polaris/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// requestContext adds the method and the peer of the request to ctx and injects
+// the request-scoped logger, retrieved by log.FromContext.
+func requestContext(ctx context.Context, method string) context.Context {
+	attrs := []slog.Attr{slog.String(log.MethodKey, method)}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		attrs = append(attrs, slog.String("peer", p.Addr.String()))
+	}
+	ctx = log.ContextWithAttrs(ctx, attrs...)
+	return log.NewContext(ctx, log.FromContext(ctx))
+}
+
+func loggerUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(requestContext(ctx, info.FullMethod), req)
+}
+
+func loggerStreamServerInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	wrapped := grpc_middleware.WrapServerStream(stream)
+	wrapped.WrappedContext = requestContext(stream.Context(), info.FullMethod)
+	return handler(srv, wrapped)
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/mdobak/go-xerrors"
+	"github.com/shenjing023/vivy-polaris/contrib/metrics/prometheus"
+	"github.com/shenjing023/vivy-polaris/errors"
+	"github.com/shenjing023/vivy-polaris/log"
+	"github.com/shenjing023/vivy-polaris/options"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+)
+
+// RecoveryHandler turns a recovered panic into the error returned to the caller,
+// the panic and its stack have been logged already. The returned error goes
+// through the error sanitizer.
+type RecoveryHandler func(ctx context.Context, p any) error
+
+// WithRecovery sets the handler of the recovered panics,
+// by default an InternalError is returned.
+func WithRecovery(h RecoveryHandler) options.Option[serverOptions] {
+	return options.NewFuncOption(func(so *serverOptions) {
+		if h != nil {
+			so.recoveryHandler = h
+		}
+	})
+}
+
+func defaultRecoveryHandler(ctx context.Context, p any) error {
+	return errors.NewInternalError()
+}
+
+// recovery recovers the panics of the handlers and of the other interceptors,
+// it is the outermost interceptor.
+type recovery struct {
+	handler   RecoveryHandler
+	sanitizer *errors.Sanitizer
+	panics    metric.Int64Counter // counts the recovered panics by method
+	metrics   *prometheus.ServerMetrics
+}
+
+// recovery returns the recovery counting the panics with the MeterProvider of
+// WithServerMeterProvider, else the global one, and with WithServerMetrics.
+func (sopt *serverOptions) recovery() *recovery {
+	mp := sopt.meterProvider
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	panics, _ := mp.Meter("github.com/shenjing023/vivy-polaris/server").Int64Counter("rpc.server.panics",
+		metric.WithDescription("Number of panics recovered from the rpc handlers"))
+	return &recovery{
+		handler:   sopt.recoveryHandler,
+		sanitizer: sopt.sanitizer,
+		panics:    panics,
+		metrics:   sopt.metrics,
+	}
+}
+
+// recoverPanic logs the panic with the stack frames and calls the handler,
+// perr is the panic value p with the stack trace
+func (r *recovery) recoverPanic(ctx context.Context, method string, p any, perr error) error {
+	r.panics.Add(ctx, 1, metric.WithAttributes(attribute.String("rpc.method", method)))
+	if r.metrics != nil {
+		r.metrics.PanicRecovered(method)
+	}
+	slog.ErrorContext(ctx, "panic recovered", "method", method, log.ErrAttr(perr))
+	return r.sanitizer.Sanitize(ctx, method, r.handler(ctx, p))
+}
+
+func (r *recovery) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (_ interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = r.recoverPanic(ctx, info.FullMethod, p, xerrors.FromRecover(p))
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+func (r *recovery) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = r.recoverPanic(stream.Context(), info.FullMethod, p, xerrors.FromRecover(p))
+			}
+		}()
+		return handler(srv, stream)
+	}
+}
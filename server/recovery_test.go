@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/shenjing023/vivy-polaris/contrib/metrics/prometheus"
+	"github.com/shenjing023/vivy-polaris/errors"
+	"github.com/shenjing023/vivy-polaris/example/pb"
+	"github.com/shenjing023/vivy-polaris/options"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestRecovery(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("boom")
+	}
+	_, err := newServerOptions().recovery().UnaryServerInterceptor()(context.Background(), nil, info, handler)
+	assert.Equal(t, codes.Internal, errors.Convert(err).Code())
+	assert.Equal(t, errors.InternalError, errors.Convert(err).Message())
+
+	var got any
+	_, err = newServerOptions(WithRecovery(func(ctx context.Context, p any) error {
+		got = p
+		return errors.NewServiceErr(codes.Unavailable, errors.NewInternalError())
+	})).recovery().UnaryServerInterceptor()(context.Background(), nil, info, handler)
+	assert.Equal(t, "boom", got)
+	assert.Equal(t, codes.Unavailable, errors.Convert(err).Code())
+}
+
+func TestRecoveryInterceptorPanic(t *testing.T) {
+	reg := prom.NewRegistry()
+	m := prometheus.NewServerMetrics(prometheus.WithRegisterer(reg))
+	var calls []string
+	srv := NewServer(WithServerMetrics(m), options.NewFuncOption(func(so *serverOptions) {
+		so.interceptors = append(so.interceptors, func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler) (interface{}, error) {
+			panic("interceptor boom")
+		})
+	}))
+	pb.RegisterGreeterServer(srv, &greeter{calls: &calls})
+	conn := serve(t, srv)
+
+	_, err := pb.NewGreeterClient(conn).SayHello(context.Background(), &pb.HelloRequest{Name: "vivy"})
+	assert.Equal(t, codes.Internal, errors.Convert(err).Code())
+	assert.Empty(t, calls)
+	assert.Nil(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP grpc_server_in_flight Number of rpcs in flight.
+# TYPE grpc_server_in_flight gauge
+grpc_server_in_flight{grpc_method="SayHello",grpc_service="helloworld.Greeter",grpc_type="unary"} 0
+# HELP grpc_server_panics_total Total number of panics recovered from the rpcs.
+# TYPE grpc_server_panics_total counter
+grpc_server_panics_total{grpc_method="SayHello",grpc_service="helloworld.Greeter"} 1
+`), "grpc_server_in_flight", "grpc_server_panics_total"))
+}
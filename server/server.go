@@ -1,38 +1,52 @@
 package server
 
 import (
-	"context"
-	"time"
-
+	"github.com/shenjing023/vivy-polaris/contrib/accesslog"
+	"github.com/shenjing023/vivy-polaris/contrib/metrics/prometheus"
 	"github.com/shenjing023/vivy-polaris/contrib/ratelimit"
+	"github.com/shenjing023/vivy-polaris/contrib/tracing"
 	"github.com/shenjing023/vivy-polaris/contrib/validator"
 	"github.com/shenjing023/vivy-polaris/errors"
+	"github.com/shenjing023/vivy-polaris/log"
 	"github.com/shenjing023/vivy-polaris/options"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
 
 	"log/slog"
 
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
-	recovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/stats"
 )
 
 func NewServer(opts ...options.Option[serverOptions]) *grpc.Server {
-	interceptors := NewServerOptions(opts...)
-	interceptors = append(interceptors, recovery.UnaryServerInterceptor(recovery.WithRecoveryHandler(func(p interface{}) (err error) {
-		return status.Errorf(codes.Internal, "panic triggered: %v", p)
-	})))
-	interceptors = append(interceptors, errors.ServerErrorInterceptor)
-	return grpc.NewServer(grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(interceptors...)))
+	sopt := newServerOptions(opts...)
+	srv := grpc.NewServer(sopt.grpcOptions()...)
+	if sopt.health {
+		registerHealth(srv)
+	}
+	if sopt.logLevelAuth != nil {
+		log.RegisterLevelService(srv, sopt.logLevelAuth)
+	}
+	return srv
 }
 
 type serverOptions struct {
-	interceptors []grpc.UnaryServerInterceptor
+	interceptors       []grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
+	health             bool
+	logLevelAuth       log.AuthFunc
+	sanitizer          *errors.Sanitizer
+	recoveryHandler    RecoveryHandler
+	metrics            *prometheus.ServerMetrics
+	opts               []grpc.ServerOption
+	tracerProvider     trace.TracerProvider
+	meterProvider      metric.MeterProvider
+	otelOpts           []otelgrpc.Option
+	otelFilter         otelgrpc.Filter
 }
 
 // WithTBRL TokenBucketRateLimiter
@@ -43,48 +57,223 @@ func WithTBRL(pairs ...ratelimit.TBPair) options.Option[serverOptions] {
 	}
 	return options.NewFuncOption(func(so *serverOptions) {
 		so.interceptors = append(so.interceptors, ratelimit.UnaryServerInterceptor(limiters...))
+		so.streamInterceptors = append(so.streamInterceptors, ratelimit.StreamServerInterceptor(limiters...))
 	})
 }
 
+// WithSWRL SlidingWindowRateLimiter
+func WithSWRL(pairs ...ratelimit.SWPair) options.Option[serverOptions] {
+	var limiters []ratelimit.RateLimiter
+	for _, p := range pairs {
+		if p.Log {
+			limiters = append(limiters, ratelimit.NewSlidingWindowLogRL(p.Limit, p.Window, p.Method))
+		} else {
+			limiters = append(limiters, ratelimit.NewSlidingWindowCounterRL(p.Limit, p.Window, p.Method))
+		}
+	}
+	return WithRateLimiters(limiters...)
+}
+
+// WithLBRL LeakyBucketRateLimiter, the requests wait up to MaxWait for their turn.
+func WithLBRL(pairs ...ratelimit.LBPair) options.Option[serverOptions] {
+	var limiters []ratelimit.RateLimiter
+	for _, p := range pairs {
+		limiters = append(limiters, ratelimit.NewLeakyBucketRL(p.Rate, p.MaxWait, p.Method))
+	}
+	return WithRateLimiters(limiters...)
+}
+
+// WithConcurrencyLimit rejects the requests beyond MaxInFlight in-flight ones.
+func WithConcurrencyLimit(pairs ...ratelimit.CLPair) options.Option[serverOptions] {
+	var limiters []ratelimit.RateLimiter
+	for _, p := range pairs {
+		limiters = append(limiters, ratelimit.NewConcurrencyRL(p.MaxInFlight, p.Method))
+	}
+	return WithRateLimiters(limiters...)
+}
+
+// WithLoadShedding sheds the requests with ResourceExhausted while the server is
+// overloaded, the low priority methods of b first.
+func WithLoadShedding(b *ratelimit.BBR) options.Option[serverOptions] {
+	return options.NewFuncOption(func(so *serverOptions) {
+		so.interceptors = append(so.interceptors, b.UnaryServerInterceptor())
+		so.streamInterceptors = append(so.streamInterceptors, b.StreamServerInterceptor())
+	})
+}
+
+// WithDistributedTBRL TokenBucketRateLimiter shared by every replica through store,
+// e.g. ratelimit.NewRedisStore, the replicas fall back to local buckets when
+// the store is unreachable.
+func WithDistributedTBRL(store ratelimit.Store, pairs ...ratelimit.TBPair) options.Option[serverOptions] {
+	var limiters []ratelimit.RateLimiter
+	for _, p := range pairs {
+		limiters = append(limiters, ratelimit.NewDistributedTokenBucketRL(store, p.Rate, p.Tokens, p.Method))
+	}
+	return WithRateLimiters(limiters...)
+}
+
+// WithRateLimiters limits the rpcs with limiters built by the ratelimit package.
+func WithRateLimiters(limiters ...ratelimit.RateLimiter) options.Option[serverOptions] {
+	return options.NewFuncOption(func(so *serverOptions) {
+		so.interceptors = append(so.interceptors, ratelimit.UnaryServerInterceptor(limiters...))
+		so.streamInterceptors = append(so.streamInterceptors, ratelimit.StreamServerInterceptor(limiters...))
+	})
+}
+
+// WithDebug logs every rpc at debug level with the redacted request and response.
+//
+// Deprecated: use WithAccessLog.
 func WithDebug(flag bool) options.Option[serverOptions] {
 	if !flag {
 		return options.NewFuncOption(func(so *serverOptions) {})
 	}
 	slog.Info("debug mode enabled")
+	return WithAccessLog(accesslog.NewLogger(accesslog.WithLevel(slog.LevelDebug),
+		accesslog.WithFields(accesslog.Request, accesslog.Response, accesslog.Peer, accesslog.Metadata)))
+}
+
+// WithAccessLog logs one record per rpc with l, the sensitive message fields
+// and metadata are redacted.
+func WithAccessLog(l *accesslog.Logger) options.Option[serverOptions] {
 	return options.NewFuncOption(func(so *serverOptions) {
-		so.interceptors = append(so.interceptors, func(ctx context.Context, req interface{},
-			info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-			slog.Debug("debug", "method", info.FullMethod, "req", req)
-			startTime := time.Now()
-			resp, err := handler(ctx, req)
-			slog.Debug("debug", "method", info.FullMethod, "cost", time.Since(startTime))
-			return resp, err
-		})
+		so.interceptors = append(so.interceptors, l.UnaryServerInterceptor())
+		so.streamInterceptors = append(so.streamInterceptors, l.StreamServerInterceptor())
 	})
 }
 
+// NewServerOptions returns the unary interceptors built from opts.
+//
+// Deprecated: use NewGRPCServerOptions, which also chains the stream
+// interceptors, the panic recovery, metrics, logger and error sanitizer ones.
 func NewServerOptions(opts ...options.Option[serverOptions]) []grpc.UnaryServerInterceptor {
+	return newServerOptions(opts...).interceptors
+}
+
+// NewGRPCServerOptions returns the grpc server options built from opts, the panic
+// recovery interceptor is always chained first followed by the metrics one and
+// the one injecting the request-scoped logger, the error sanitizer interceptor
+// is always chained last.
+func NewGRPCServerOptions(opts ...options.Option[serverOptions]) []grpc.ServerOption {
+	return newServerOptions(opts...).grpcOptions()
+}
+
+func newServerOptions(opts ...options.Option[serverOptions]) *serverOptions {
 	sopt := &serverOptions{
-		interceptors: make([]grpc.UnaryServerInterceptor, 0),
+		interceptors:       make([]grpc.UnaryServerInterceptor, 0),
+		streamInterceptors: make([]grpc.StreamServerInterceptor, 0),
+		sanitizer:          errors.NewSanitizer(),
+		recoveryHandler:    defaultRecoveryHandler,
+		otelFilter:         tracing.DefaultFilter(),
 	}
 	for _, opt := range opts {
 		opt.Apply(sopt)
 	}
-	return sopt.interceptors
+	return sopt
+}
+
+func (sopt *serverOptions) grpcOptions() []grpc.ServerOption {
+	sopt.interceptors = append([]grpc.UnaryServerInterceptor{loggerUnaryServerInterceptor}, sopt.interceptors...)
+	sopt.streamInterceptors = append([]grpc.StreamServerInterceptor{loggerStreamServerInterceptor}, sopt.streamInterceptors...)
+	if sopt.metrics != nil {
+		sopt.interceptors = append([]grpc.UnaryServerInterceptor{sopt.metrics.UnaryServerInterceptor()}, sopt.interceptors...)
+		sopt.streamInterceptors = append([]grpc.StreamServerInterceptor{sopt.metrics.StreamServerInterceptor()}, sopt.streamInterceptors...)
+	}
+	r := sopt.recovery()
+	sopt.interceptors = append(sopt.interceptors, sopt.sanitizer.UnaryServerInterceptor())
+	sopt.interceptors = append([]grpc.UnaryServerInterceptor{r.UnaryServerInterceptor()}, sopt.interceptors...)
+	sopt.streamInterceptors = append(sopt.streamInterceptors, sopt.sanitizer.StreamServerInterceptor())
+	sopt.streamInterceptors = append([]grpc.StreamServerInterceptor{r.StreamServerInterceptor()}, sopt.streamInterceptors...)
+	if sopt.tracerProvider != nil || sopt.meterProvider != nil {
+		sopt.opts = append(sopt.opts, grpc.StatsHandler(sopt.statsHandler()))
+	}
+	return append(sopt.opts,
+		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(sopt.interceptors...)),
+		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(sopt.streamInterceptors...)),
+	)
 }
 
-func WithServerTracing(tp *sdktrace.TracerProvider) options.Option[serverOptions] {
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+// WithServerTracing traces the rpcs, streams and message events included, with tp.
+// The W3C Trace Context and Baggage are extracted unless otelgrpc.WithPropagators
+// is in opts, e.g. tracing.NewPropagator(tracing.B3).
+// The global TracerProvider and propagator are left alone.
+func WithServerTracing(tp trace.TracerProvider, opts ...otelgrpc.Option) options.Option[serverOptions] {
 	return options.NewFuncOption(func(so *serverOptions) {
-		so.interceptors = append(so.interceptors, otelgrpc.UnaryServerInterceptor())
+		so.tracerProvider = tp
+		so.otelOpts = append(so.otelOpts, opts...)
+	})
+}
+
+// WithTracingFilter sets the filter of the traced and measured rpcs,
+// default is tracing.DefaultFilter excluding the health checks and the reflection.
+// A nil filter keeps every rpc.
+func WithTracingFilter(f otelgrpc.Filter) options.Option[serverOptions] {
+	return options.NewFuncOption(func(so *serverOptions) {
+		so.otelFilter = f
 	})
 }
 
 // WithServerValidator validate fields,
-// all==true return all fields error, otherwise return first error
+// all==true return all fields error, otherwise return first error.
+// For streaming rpcs every received message is validated.
 func WithServerValidator(all bool) options.Option[serverOptions] {
 	return options.NewFuncOption(func(so *serverOptions) {
 		so.interceptors = append(so.interceptors, validator.UnaryServerInterceptor(all))
+		so.streamInterceptors = append(so.streamInterceptors, validator.StreamServerInterceptor(all))
+	})
+}
+
+// WithLogLevelService registers the admin grpc service changing the log levels
+// at runtime, see log.RegisterLevelService. It is served on the port of the
+// server so every call must be authorized by auth, e.g. log.AllowPeers of the
+// internal network. A nil auth does not register the service, prefer
+// WithLogLevelEndpoint on the internal metrics port.
+func WithLogLevelService(auth log.AuthFunc) options.Option[serverOptions] {
+	return options.NewFuncOption(func(so *serverOptions) {
+		so.logLevelAuth = auth
 	})
 }
+
+// WithErrorSanitizer sets the policy masking the handler errors,
+// by default only *errors.Error and status errors pass through.
+func WithErrorSanitizer(s *errors.Sanitizer) options.Option[serverOptions] {
+	return options.NewFuncOption(func(so *serverOptions) {
+		so.sanitizer = s
+	})
+}
+
+// WithServerMetrics records the prometheus metrics of every rpc, including the
+// requests rejected by the rate limiter and the validator. App serves them with
+// WithMetricsHandler(m.Handler()) when m uses a custom registerer.
+func WithServerMetrics(m *prometheus.ServerMetrics) options.Option[serverOptions] {
+	return options.NewFuncOption(func(so *serverOptions) {
+		so.metrics = m
+	})
+}
+
+// WithServerMeterProvider records the otelgrpc rpc metrics with mp,
+// e.g. a MeterProvider from metrics.NewOTLPMeterProvider.
+func WithServerMeterProvider(mp metric.MeterProvider) options.Option[serverOptions] {
+	return options.NewFuncOption(func(so *serverOptions) {
+		so.meterProvider = mp
+	})
+}
+
+// statsHandler returns the otelgrpc stats handler tracing and measuring the rpcs,
+// the providers which are not set are noop.
+func (sopt *serverOptions) statsHandler() stats.Handler {
+	var tp trace.TracerProvider = tracenoop.NewTracerProvider()
+	if sopt.tracerProvider != nil {
+		tp = sopt.tracerProvider
+	}
+	var mp metric.MeterProvider = metricnoop.NewMeterProvider()
+	if sopt.meterProvider != nil {
+		mp = sopt.meterProvider
+	}
+	opts := append([]otelgrpc.Option{
+		otelgrpc.WithTracerProvider(tp),
+		otelgrpc.WithMeterProvider(mp),
+		otelgrpc.WithPropagators(tracing.NewPropagator()),
+		otelgrpc.WithMessageEvents(otelgrpc.ReceivedEvents, otelgrpc.SentEvents),
+	}, sopt.otelOpts...)
+	return tracing.NewServerHandler(sopt.otelFilter, opts...)
+}
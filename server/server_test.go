@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/shenjing023/vivy-polaris/example/pb"
+	"github.com/shenjing023/vivy-polaris/options"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type greeter struct {
+	pb.UnimplementedGreeterServer
+	calls *[]string
+}
+
+func (g *greeter) SayHello(ctx context.Context, req *pb.HelloRequest) (*pb.HelloReply, error) {
+	*g.calls = append(*g.calls, "handler")
+	return &pb.HelloReply{Message: "hello " + req.GetName()}, nil
+}
+
+// echoDesc is a bidi streaming service echoing the received messages.
+var echoDesc = grpc.ServiceDesc{
+	ServiceName: "test.Echo",
+	HandlerType: (*any)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Echo",
+		ServerStreams: true,
+		ClientStreams: true,
+		Handler: func(srv any, stream grpc.ServerStream) error {
+			*srv.(*greeter).calls = append(*srv.(*greeter).calls, "handler")
+			m := new(emptypb.Empty)
+			if err := stream.RecvMsg(m); err != nil {
+				return err
+			}
+			return stream.SendMsg(m)
+		},
+	}},
+}
+
+// withRecorder chains the interceptors appending name to calls.
+func withRecorder(name string, calls *[]string) options.Option[serverOptions] {
+	return options.NewFuncOption(func(so *serverOptions) {
+		so.interceptors = append(so.interceptors, func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler) (interface{}, error) {
+			*calls = append(*calls, name)
+			return handler(ctx, req)
+		})
+		so.streamInterceptors = append(so.streamInterceptors, func(srv interface{}, stream grpc.ServerStream,
+			info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			*calls = append(*calls, name)
+			return handler(srv, stream)
+		})
+	})
+}
+
+// serve serves srv over an in-memory listener and returns a client connection.
+func serve(t *testing.T, srv *grpc.Server) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestInterceptorOrder(t *testing.T) {
+	var calls []string
+	g := &greeter{calls: &calls}
+	srv := NewServer(withRecorder("first", &calls), withRecorder("second", &calls))
+	pb.RegisterGreeterServer(srv, g)
+	srv.RegisterService(&echoDesc, g)
+	conn := serve(t, srv)
+
+	_, err := pb.NewGreeterClient(conn).SayHello(context.Background(), &pb.HelloRequest{Name: "vivy"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"first", "second", "handler"}, calls)
+
+	calls = nil
+	stream, err := conn.NewStream(context.Background(), &echoDesc.Streams[0], "/test.Echo/Echo")
+	assert.Nil(t, err)
+	assert.Nil(t, stream.SendMsg(new(emptypb.Empty)))
+	assert.Nil(t, stream.CloseSend())
+	assert.Nil(t, stream.RecvMsg(new(emptypb.Empty)))
+	assert.Equal(t, []string{"first", "second", "handler"}, calls)
+}